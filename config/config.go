@@ -2,14 +2,19 @@
 package config
 
 import (
-	"crypto/sha256"
+	"errors"
 	"flag"
+	"fmt"
 	"hash"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/White-AK111/fileworker/hasher"
+	"github.com/White-AK111/fileworker/ignore"
 	"github.com/kkyr/fig"
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
@@ -17,27 +22,161 @@ import (
 )
 
 const (
-	usagePath = "use this flag for set source directory"
-	usageRm   = "use this flag for delete duplicate files"
-	usageCp   = "use this flag for random copy files"
-	usageGo   = "use this flag for set max count of goroutines"
+	usagePath          = "use this flag for set source directory, can be repeated for find duplicates across several directories, first directory has highest priority"
+	usageReference     = "use this flag for set read-only reference directory, only files in source directories which duplicate files in it are found, can be repeated"
+	usageRm            = "use this flag for delete duplicate files"
+	usageCp            = "use this flag for random copy files"
+	usageGo            = "use this flag for set max count of goroutines"
+	usageNoCache       = "use this flag for disable persistent cache of file hashes"
+	usageHash          = "use this flag for set hash algorithm (sha256, sha1, md5, blake2b, xxhash64, crc32 or registered by user)"
+	usageQuarantine    = "use this flag for move duplicate files to quarantine directory instead of delete, command restore put it back"
+	usageTrash         = "use this flag for move duplicate files to trash instead of delete, files can be restored from file manager"
+	usageVerify        = "use this flag for compare duplicate with original byte by byte before delete, files with different content are skipped"
+	usageLink          = "use this flag for replace duplicate files by links to original instead of delete (hard, sym, reflink)"
+	usageLinkTarget    = "use this flag for set target of symbolic links (relative, absolute)"
+	usagePreserveAttrs = "use this flag for skip duplicate files, which permissions or owner can't be preserved by link"
+	usageNoVerifyHash  = "use this flag for disable byte by byte verify of duplicates found by non-cryptographic hash"
+	usageDryRun        = "use this flag for print and save plan of actions with duplicate files without change files, command apply run plan"
+	usagePlan          = "use this flag for set file for save plan of dry run"
+	usageOriginal      = "use this flag for set policies of select original file, next policy break ties of previous (oldest, newest, shortest-path, shallowest, preferred, longest-name, root)"
+	usagePrefer        = "use this flag for set preferred directories for original files, used by policy preferred, can be repeated"
+	usageInteractive   = "use this flag for review every group of duplicate files and choose file for keep before resolve"
+	usageInclude       = "use this flag for scan only files matched by pattern (gitignore-style glob or regular expression with prefix re:), can be repeated"
+	usageExclude       = "use this flag for skip directories and files matched by pattern (gitignore-style glob or regular expression with prefix re:), can be repeated"
+	usageIgnoreFiles   = "use this flag for skip directories and files by rules of .gitignore, .ignore and .fileworkerignore in every directory"
+	usageMinSize       = "use this flag for set min size of files for compare in bytes, by default empty files are skipped"
+	usageMaxSize       = "use this flag for set max size of files for compare in bytes, 0 - without limit"
+	usageModBefore     = "use this flag for compare only files modified before date (2006-01-02 or RFC3339)"
+	usageModAfter      = "use this flag for compare only files modified after date (2006-01-02 or RFC3339)"
+	usageExt           = "use this flag for compare only files with extensions, can be repeated"
+	usageMime          = "use this flag for compare only files with MIME types detected by content (image/png, image/*), can be repeated"
+	usageSymlinks      = "use this flag for set policy of symbolic links on scan (skip, follow), loops of links are detected, targets outside of scanned directories are skipped"
+	usageXdev          = "use this flag for scan only file system of source directory, directories on other file systems are skipped"
+	usageDirs          = "use this flag for find duplicate directories (same names and content of files), top-most directories are reported and deleted by one action"
+	usageProtect       = "use this flag for set glob of protected paths, protected files can be original, but never deleted, moved or linked, can be repeated"
 )
 
+// defaultMinSize min size of files for compare without min size in configuration, empty files aren't duplicates
+const defaultMinSize = 1
+
+// modes for replace duplicate files by links
+const (
+	LinkHard    = "hard"    // replace duplicate by hard link to original
+	LinkSym     = "sym"     // replace duplicate by symbolic link to original
+	LinkReflink = "reflink" // share extents of duplicate with original by copy-on-write reflink
+)
+
+// targets of symbolic links
+const (
+	LinkTargetRelative = "relative" // symbolic link contains path to original relative to directory of link
+	LinkTargetAbsolute = "absolute" // symbolic link contains absolute path to original
+)
+
+// policies of symbolic links on scan
+const (
+	SymlinksSkip   = "skip"   // symbolic links are skipped
+	SymlinksFollow = "follow" // symbolic links to files and directories are followed
+)
+
+// policies for select original file in group of duplicate files
+const (
+	OriginalOldest       = "oldest"        // file with oldest modification time is original
+	OriginalNewest       = "newest"        // file with newest modification time is original
+	OriginalShortestPath = "shortest-path" // file with shortest path is original
+	OriginalShallowest   = "shallowest"    // file with smallest depth of directories is original
+	OriginalPreferred    = "preferred"     // file from first preferred directory is original
+	OriginalLongestName  = "longest-name"  // file with longest name is original
+	OriginalRoot         = "root"          // file from first source directory is original
+)
+
+// listValue type for flags with list of values, values can be separated by comma (if split is set) or flag can be repeated,
+// first value from command line replace value from configuration file
+type listValue struct {
+	list  *[]string
+	split bool
+	set   bool
+}
+
+// newListValue function initialize new listValue for list, patterns with comma need flag without split, return *listValue
+func newListValue(list *[]string, split bool) *listValue {
+	return &listValue{list: list, split: split}
+}
+
+// String method return values of list separated by comma
+func (l *listValue) String() string {
+	if l == nil || l.list == nil {
+		return ""
+	}
+
+	return strings.Join(*l.list, ",")
+}
+
+// Set method add values separated by comma to list, return error
+func (l *listValue) Set(value string) error {
+	if !l.set {
+		*l.list = nil
+		l.set = true
+	}
+	if !l.split {
+		*l.list = append(*l.list, value)
+		return nil
+	}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l.list = append(*l.list, v)
+		}
+	}
+
+	return nil
+}
+
 // Config structure for all settings of application
 type Config struct {
 	App struct {
-		HashAlgorithm    hash.Hash          // hash algorithm for use, don't load from configuration file
-		Logger           *zap.Logger        // logger for use, don't load from configuration file
-		Tracer           opentracing.Tracer // tracer for use, don't load from configuration file
-		LogLevel         int                `fig:"logLevel" default:"0"`          // flag for log level (0-info, 1-warn, -1-debug, 2-error, 4-panic, 5-fatal)
-		SourcePath       string             `fig:"sourcePath" default:"."`        // source directory
-		CountGoroutine   int                `fig:"countGoroutine" default:"10"`   // count of goroutines
-		CountRndCopyIter int                `fig:"countRndCopyIter" default:"10"` // random count for create copy of files
-		SizeCopyBuffer   int                `fig:"sizeCopyBuffer" default:"512"`  // copy buffer size
-		FlagDelete       bool               `fig:"flagDelete"`                    // flag for delete duplicate files
-		FlagRandCopy     bool               `fig:"flagRandCopy"`                  // flag fo random copy files
-		RunInTest        bool               `fig:"runInTest"`                     // flag for testing, don't get approval fo delete from user
-		DoPanic          bool               `fig:"doPanic"`                       // flag for testing, do panic
+		NewHash           func() hash.Hash   // factory of hash algorithm, every worker create own hash, don't load from configuration file
+		Logger            *zap.Logger        // logger for use, don't load from configuration file
+		Tracer            opentracing.Tracer // tracer for use, don't load from configuration file
+		LogLevel          int                `fig:"logLevel" default:"0"`           // flag for log level (0-info, 1-warn, -1-debug, 2-error, 4-panic, 5-fatal)
+		SourcePath        string             `fig:"sourcePath" default:"."`         // source directory
+		SourcePaths       []string           `fig:"sourcePaths"`                    // source directories, first directory has highest priority, replace source directory
+		ReferencePaths    []string           `fig:"referencePaths"`                 // read-only reference directories, files in it are originals and never resolved
+		CountGoroutine    int                `fig:"countGoroutine" default:"10"`    // count of goroutines
+		CountRndCopyIter  int                `fig:"countRndCopyIter" default:"10"`  // random count for create copy of files
+		SizeCopyBuffer    int                `fig:"sizeCopyBuffer" default:"512"`   // copy buffer size
+		SizePartialHash   int                `fig:"sizePartialHash" default:"4096"` // size of head and tail of file for partial hash
+		HashAlgorithmName string             `fig:"hashAlgorithm" default:"sha256"` // name of hash algorithm from registry
+		FlagNoVerifyHash  bool               `fig:"flagNoVerifyHash"`               // flag for disable verify of duplicates found by non-cryptographic hash
+		CachePath         string             `fig:"cachePath"`                      // path to file of hash cache, by default in user cache directory
+		FlagNoCache       bool               `fig:"flagNoCache"`                    // flag for disable hash cache
+		LinkMode          string             `fig:"linkMode"`                       // mode for replace duplicate files by links to original (hard, sym, reflink)
+		LinkTarget        string             `fig:"linkTarget" default:"relative"`  // target of symbolic links (relative, absolute)
+		FlagPreserveAttrs bool               `fig:"flagPreserveAttrs"`              // flag for preserve permissions and owner of duplicate files
+		QuarantinePath    string             `fig:"quarantinePath"`                 // quarantine directory for move duplicate files
+		FlagTrash         bool               `fig:"flagTrash"`                      // flag for move duplicate files to trash
+		JournalPath       string             `fig:"journalPath"`                    // directory for undo journals, by default in user cache directory
+		FlagDryRun        bool               `fig:"flagDryRun"`                     // flag for save plan of actions with duplicate files without change files
+		FlagInteractive   bool               `fig:"flagInteractive"`                // flag for review every group of duplicate files by user before resolve
+		PlanPath          string             `fig:"planPath"`                       // file for save plan of dry run, by default in user cache directory
+		OriginalPolicy    []string           `fig:"originalPolicy"`                 // policies for select original file, next policy break ties of previous
+		PreferredPaths    []string           `fig:"preferredPaths"`                 // preferred directories for original files, first directory has highest priority
+		ProtectedPaths    []string           `fig:"protectedPaths"`                 // globs of protected paths, files never deleted, moved or linked
+		Include           []string           `fig:"include"`                        // patterns of files for scan, relative to source directory
+		Exclude           []string           `fig:"exclude"`                        // patterns of directories and files for skip on scan, relative to source directory
+		FlagIgnoreFiles   bool               `fig:"flagIgnoreFiles"`                // flag for apply rules of ignore files in every directory on scan
+		SymlinkPolicy     string             `fig:"symlinks" default:"skip"`        // policy of symbolic links on scan (skip, follow)
+		FlagXdev          bool               `fig:"flagXdev"`                       // flag for scan only file system of source directory
+		FlagDirs          bool               `fig:"flagDirs"`                       // flag for find duplicate directories
+		MinSize           *int64             `fig:"minSize"`                        // min size of files for compare, unset - 1 byte (fig replace zero value by default tag, explicit 0 is lost)
+		MaxSize           int64              `fig:"maxSize"`                        // max size of files for compare, 0 - without limit
+		ModifiedBefore    string             `fig:"modifiedBefore"`                 // compare only files modified before date
+		ModifiedAfter     string             `fig:"modifiedAfter"`                  // compare only files modified after date
+		Extensions        []string           `fig:"extensions"`                     // compare only files with extensions
+		MimeTypes         []string           `fig:"mimeTypes"`                      // compare only files with MIME types, detected by content
+		FlagVerify        bool               `fig:"flagVerify"`                     // flag for compare duplicate with original byte by byte before delete
+		FlagDelete        bool               `fig:"flagDelete"`                     // flag for delete duplicate files
+		FlagRandCopy      bool               `fig:"flagRandCopy"`                   // flag fo random copy files
+		RunInTest         bool               `fig:"runInTest"`                      // flag for testing, don't get approval fo delete from user
+		DoPanic           bool               `fig:"doPanic"`                        // flag for testing, do panic
 	} `fig:"app"`
 }
 
@@ -92,22 +231,134 @@ func Init() (*Config, error) {
 
 	cfg.App.Logger = logger
 
-	cfg.App.HashAlgorithm = sha256.New()
+	if err = cfg.setHashAlgorithm(); err != nil {
+		return nil, err
+	}
+
+	// tracer by default do nothing, application can replace it
+	cfg.App.Tracer = opentracing.NoopTracer{}
 
 	return &cfg, err
 }
 
 // InitFlags method for initialize flags and prepare source path to ABS
 func (c *Config) InitFlags() error {
-	flag.StringVar(&c.App.SourcePath, "path", c.App.SourcePath, usagePath)
+	flag.Var(newListValue(&c.App.SourcePaths, false), "path", usagePath)
+	flag.Var(newListValue(&c.App.ReferencePaths, false), "reference", usageReference)
 	flag.BoolVar(&c.App.FlagDelete, "rm", c.App.FlagDelete, usageRm)
+	flag.BoolVar(&c.App.FlagVerify, "verify", c.App.FlagVerify, usageVerify)
+	flag.StringVar(&c.App.LinkMode, "link", c.App.LinkMode, usageLink)
+	flag.StringVar(&c.App.QuarantinePath, "quarantine", c.App.QuarantinePath, usageQuarantine)
+	flag.BoolVar(&c.App.FlagTrash, "trash", c.App.FlagTrash, usageTrash)
+	flag.StringVar(&c.App.LinkTarget, "link-target", c.App.LinkTarget, usageLinkTarget)
+	flag.BoolVar(&c.App.FlagPreserveAttrs, "preserve-attrs", c.App.FlagPreserveAttrs, usagePreserveAttrs)
+	flag.BoolVar(&c.App.FlagDryRun, "dry-run", c.App.FlagDryRun, usageDryRun)
+	flag.BoolVar(&c.App.FlagInteractive, "interactive", c.App.FlagInteractive, usageInteractive)
+	flag.StringVar(&c.App.PlanPath, "plan", c.App.PlanPath, usagePlan)
+	flag.Var(newListValue(&c.App.OriginalPolicy, true), "original", usageOriginal)
+	flag.Var(newListValue(&c.App.PreferredPaths, true), "prefer", usagePrefer)
+	flag.Var(newListValue(&c.App.ProtectedPaths, true), "protect", usageProtect)
+	flag.Var(newListValue(&c.App.Include, false), "include", usageInclude)
+	flag.Var(newListValue(&c.App.Exclude, false), "exclude", usageExclude)
+	flag.BoolVar(&c.App.FlagIgnoreFiles, "ignore-files", c.App.FlagIgnoreFiles, usageIgnoreFiles)
+	flag.StringVar(&c.App.SymlinkPolicy, "symlinks", c.App.SymlinkPolicy, usageSymlinks)
+	flag.BoolVar(&c.App.FlagXdev, "xdev", c.App.FlagXdev, usageXdev)
+	flag.BoolVar(&c.App.FlagDirs, "dirs", c.App.FlagDirs, usageDirs)
+	minSize := c.GetMinSize()
+	flag.Int64Var(&minSize, "min-size", minSize, usageMinSize)
+	flag.Int64Var(&c.App.MaxSize, "max-size", c.App.MaxSize, usageMaxSize)
+	flag.StringVar(&c.App.ModifiedBefore, "modified-before", c.App.ModifiedBefore, usageModBefore)
+	flag.StringVar(&c.App.ModifiedAfter, "modified-after", c.App.ModifiedAfter, usageModAfter)
+	flag.Var(newListValue(&c.App.Extensions, true), "ext", usageExt)
+	flag.Var(newListValue(&c.App.MimeTypes, true), "mime", usageMime)
 	flag.BoolVar(&c.App.FlagRandCopy, "cp", c.App.FlagRandCopy, usageCp)
 	flag.IntVar(&c.App.CountGoroutine, "go", c.App.CountGoroutine, usageGo)
+	flag.BoolVar(&c.App.FlagNoCache, "no-cache", c.App.FlagNoCache, usageNoCache)
+	flag.StringVar(&c.App.HashAlgorithmName, "hash", c.App.HashAlgorithmName, usageHash)
+	flag.BoolVar(&c.App.FlagNoVerifyHash, "no-verify-hash", c.App.FlagNoVerifyHash, usageNoVerifyHash)
 	flag.Parse()
+	c.SetMinSize(minSize)
+
+	if err := c.setHashAlgorithm(); err != nil {
+		c.App.Logger.Warn("Error on set hash algorithm",
+			zap.String("algorithm", c.App.HashAlgorithmName),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateResolveMode(); err != nil {
+		c.App.Logger.Warn("Error on check mode for resolve duplicate files",
+			zap.String("link", c.App.LinkMode),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateOriginalPolicy(); err != nil {
+		c.App.Logger.Warn("Error on check policies for select original file",
+			zap.Strings("original", c.App.OriginalPolicy),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateProtectedPaths(); err != nil {
+		c.App.Logger.Warn("Error on check globs of protected paths",
+			zap.Strings("protect", c.App.ProtectedPaths),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateFilters(); err != nil {
+		c.App.Logger.Warn("Error on check patterns for filter files",
+			zap.Strings("include", c.App.Include),
+			zap.Strings("exclude", c.App.Exclude),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateSymlinkPolicy(); err != nil {
+		c.App.Logger.Warn("Error on check policy of symbolic links",
+			zap.String("symlinks", c.App.SymlinkPolicy),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateCandidateFilters(); err != nil {
+		c.App.Logger.Warn("Error on check filters of files for compare",
+			zap.Int64("min size", c.GetMinSize()),
+			zap.Int64("max size", c.App.MaxSize),
+			zap.String("modified before", c.App.ModifiedBefore),
+			zap.String("modified after", c.App.ModifiedAfter),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateCountGoroutine(); err != nil {
+		c.App.Logger.Warn("Error on check count of goroutines",
+			zap.Int("go", c.App.CountGoroutine),
+			zap.Error(err),
+		)
+		return err
+	}
 
 	if err := c.setABSPath(); err != nil {
 		c.App.Logger.Warn("Error on get ABS path from source path",
-			zap.String("path", c.App.SourcePath),
+			zap.Strings("path", c.GetSourcePaths()),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	if err := c.validateSourcePaths(); err != nil {
+		c.App.Logger.Warn("Error on check source directories",
+			zap.Strings("path", c.GetSourcePaths()),
+			zap.Strings("reference", c.App.ReferencePaths),
 			zap.Error(err),
 		)
 		return err
@@ -116,7 +367,7 @@ func (c *Config) InitFlags() error {
 	return nil
 }
 
-// setABSPath method prepare source path to ABS
+// setABSPath method prepare source path and quarantine path to ABS
 func (c *Config) setABSPath() error {
 	// get absolut filepath for source path
 	sourcePath, err := filepath.Abs(c.App.SourcePath)
@@ -125,9 +376,302 @@ func (c *Config) setABSPath() error {
 	}
 	c.App.SourcePath = sourcePath
 
+	for i, dir := range c.App.SourcePaths {
+		sourcePath, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		c.App.SourcePaths[i] = sourcePath
+	}
+	for i, dir := range c.App.ReferencePaths {
+		referencePath, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		c.App.ReferencePaths[i] = referencePath
+	}
+	// first source directory is main, it used by actions with one source directory
+	if len(c.App.SourcePaths) > 0 {
+		c.App.SourcePath = c.App.SourcePaths[0]
+	}
+
+	if c.App.QuarantinePath != "" {
+		quarantinePath, err := filepath.Abs(c.App.QuarantinePath)
+		if err != nil {
+			return err
+		}
+		c.App.QuarantinePath = quarantinePath
+	}
+
+	for i, dir := range c.App.PreferredPaths {
+		preferredPath, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		c.App.PreferredPaths[i] = preferredPath
+	}
+
+	// glob without separator match name of file or directory in any place, don't change it
+	for i, pattern := range c.App.ProtectedPaths {
+		if !strings.ContainsRune(pattern, filepath.Separator) {
+			continue
+		}
+		protectedPath, err := filepath.Abs(pattern)
+		if err != nil {
+			return err
+		}
+		c.App.ProtectedPaths[i] = protectedPath
+	}
+
+	return nil
+}
+
+// setHashAlgorithm method set factory of hash algorithm from registry by name
+func (c *Config) setHashAlgorithm() error {
+	algorithm, err := hasher.Get(c.App.HashAlgorithmName)
+	if err != nil {
+		return err
+	}
+	c.App.NewHash = algorithm.New
+
+	return nil
+}
+
+// validateResolveMode method check mode for resolve duplicate files, only one mode can be used
+func (c *Config) validateResolveMode() error {
+	switch c.App.LinkMode {
+	case "", LinkHard, LinkSym, LinkReflink:
+	default:
+		return fmt.Errorf("unknown link mode %q", c.App.LinkMode)
+	}
+
+	switch c.App.LinkTarget {
+	case LinkTargetRelative, LinkTargetAbsolute:
+	default:
+		return fmt.Errorf("unknown target of symbolic links %q", c.App.LinkTarget)
+	}
+
+	countModes := 0
+	for _, set := range []bool{c.App.FlagDelete, c.App.LinkMode != "", c.App.QuarantinePath != "", c.App.FlagTrash} {
+		if set {
+			countModes++
+		}
+	}
+	if countModes > 1 {
+		return errors.New("use only one mode for resolve duplicate files: delete, link, quarantine or trash")
+	}
+
+	// directory is resolved by one action, only delete is supported
+	if c.App.FlagDirs && (c.App.LinkMode != "" || c.App.QuarantinePath != "" || c.App.FlagTrash) {
+		return errors.New("duplicate directories can be only deleted, don't use link, quarantine or trash with flag dirs")
+	}
+
+	return nil
+}
+
+// validateSourcePaths method check source and reference directories don't repeat and don't contain each other, files can't be found twice
+func (c *Config) validateSourcePaths() error {
+	sourcePaths := c.GetScanPaths()
+	for i, dir := range sourcePaths {
+		for _, other := range sourcePaths[i+1:] {
+			if isSubPath(dir, other) || isSubPath(other, dir) {
+				return fmt.Errorf("source directories %q and %q overlap", dir, other)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isSubPath function check path is equal to directory or placed in it, return bool
+func isSubPath(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// validateOriginalPolicy method check policies for select original file, policy preferred need preferred directories
+func (c *Config) validateOriginalPolicy() error {
+	for _, policy := range c.App.OriginalPolicy {
+		switch policy {
+		case OriginalOldest, OriginalNewest, OriginalShortestPath, OriginalShallowest, OriginalLongestName, OriginalRoot:
+		case OriginalPreferred:
+			if len(c.App.PreferredPaths) == 0 {
+				return errors.New("policy preferred need preferred directories")
+			}
+		default:
+			return fmt.Errorf("unknown policy for select original file %q", policy)
+		}
+	}
+
+	return nil
+}
+
+// validateProtectedPaths method check syntax of globs of protected paths
+func (c *Config) validateProtectedPaths() error {
+	for _, pattern := range c.App.ProtectedPaths {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("wrong glob of protected path %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// validateFilters method check syntax of patterns for include and exclude files
+func (c *Config) validateFilters() error {
+	if _, err := ignore.NewMatcher(c.App.Include); err != nil {
+		return err
+	}
+	_, err := ignore.NewMatcher(c.App.Exclude)
+
+	return err
+}
+
+// validateSymlinkPolicy method check policy of symbolic links
+func (c *Config) validateSymlinkPolicy() error {
+	switch c.App.SymlinkPolicy {
+	case SymlinksSkip, SymlinksFollow:
+		return nil
+	default:
+		return fmt.Errorf("unknown policy of symbolic links %q", c.App.SymlinkPolicy)
+	}
+}
+
+// validateCountGoroutine method check count of goroutines, without goroutines files aren't read and run wait forever
+func (c *Config) validateCountGoroutine() error {
+	if c.App.CountGoroutine < 1 {
+		return fmt.Errorf("count of goroutines %d less than 1", c.App.CountGoroutine)
+	}
+
 	return nil
 }
 
+// validateCandidateFilters method check size, dates and MIME types of filters of files for compare
+func (c *Config) validateCandidateFilters() error {
+	minSize := c.GetMinSize()
+	if minSize < 0 || c.App.MaxSize < 0 {
+		return errors.New("size of files can't be negative")
+	}
+	if c.App.MaxSize > 0 && minSize > c.App.MaxSize {
+		return fmt.Errorf("min size %d more than max size %d", minSize, c.App.MaxSize)
+	}
+
+	if _, err := c.GetModifiedBefore(); err != nil {
+		return err
+	}
+	if _, err := c.GetModifiedAfter(); err != nil {
+		return err
+	}
+
+	for _, mimeType := range c.App.MimeTypes {
+		if _, err := path.Match(mimeType, ""); err != nil || !strings.Contains(mimeType, "/") {
+			return fmt.Errorf("wrong MIME type %q", mimeType)
+		}
+	}
+
+	return nil
+}
+
+// GetSourcePaths method return source directories, without list of source directories return source directory
+func (c *Config) GetSourcePaths() []string {
+	if len(c.App.SourcePaths) > 0 {
+		return c.App.SourcePaths
+	}
+
+	return []string{c.App.SourcePath}
+}
+
+// GetScanPaths method return all directories for scan: reference directories and source directories
+func (c *Config) GetScanPaths() []string {
+	scanPaths := make([]string, 0, len(c.App.ReferencePaths)+len(c.App.SourcePaths)+1)
+	scanPaths = append(scanPaths, c.App.ReferencePaths...)
+
+	return append(scanPaths, c.GetSourcePaths()...)
+}
+
+// GetMinSize method return min size of files for compare, without min size in configuration empty files are skipped, return int64
+func (c *Config) GetMinSize() int64 {
+	if c.App.MinSize == nil {
+		return defaultMinSize
+	}
+
+	return *c.App.MinSize
+}
+
+// SetMinSize method set min size of files for compare, 0 compare empty files too
+func (c *Config) SetMinSize(size int64) {
+	c.App.MinSize = &size
+}
+
+// GetModifiedBefore method return date for compare only files modified before it, without date return zero time
+func (c *Config) GetModifiedBefore() (time.Time, error) {
+	return parseDate(c.App.ModifiedBefore)
+}
+
+// GetModifiedAfter method return date for compare only files modified after it, without date return zero time
+func (c *Config) GetModifiedAfter() (time.Time, error) {
+	return parseDate(c.App.ModifiedAfter)
+}
+
+// parseDate function parse date in format 2006-01-02 (in local time zone) or RFC3339, empty value return zero time
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("wrong date %q, use 2006-01-02 or RFC3339", value)
+	}
+
+	return date, nil
+}
+
+// GetCachePath method return path to file of hash cache, by default file place in user cache directory
+func (c *Config) GetCachePath() (string, error) {
+	if c.App.CachePath != "" {
+		return c.App.CachePath, nil
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "fileworker", "cache.db"), nil
+}
+
+// GetJournalPath method return directory for undo journals, by default directory place in user cache directory
+func (c *Config) GetJournalPath() (string, error) {
+	if c.App.JournalPath != "" {
+		return c.App.JournalPath, nil
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "fileworker", "journal"), nil
+}
+
+// GetPlanPath method return file for save plan of dry run, by default file with time of run place in user cache directory
+func (c *Config) GetPlanPath() (string, error) {
+	if c.App.PlanPath != "" {
+		return c.App.PlanPath, nil
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "fileworker", "plan", fmt.Sprintf("plan-%s.json", time.Now().Format("20060102-150405"))), nil
+}
+
 // BuiltinLogger custom logger
 type BuiltinLogger struct {
 	logger *log.Logger
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"io/ioutil"
 	"log"
+	"path/filepath"
 	"testing"
+
+	"github.com/kkyr/fig"
 )
 
 func TestInit(t *testing.T) {
@@ -15,3 +19,231 @@ func TestInit(t *testing.T) {
 		log.Fatalf("error: can't load configuration: %s", err)
 	}
 }
+
+func TestSetHashAlgorithm(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	cfg.App.HashAlgorithmName = "xxhash64"
+	if err = cfg.setHashAlgorithm(); err != nil {
+		t.Fatalf("error: can't set hash algorithm: %s", err)
+	}
+
+	cfg.App.HashAlgorithmName = "unknown"
+	if err = cfg.setHashAlgorithm(); err == nil {
+		t.Fatal("error: set unknown hash algorithm")
+	}
+}
+
+func TestListValue(t *testing.T) {
+	list := []string{"from config"}
+	value := newListValue(&list, true)
+
+	if err := value.Set("a,b"); err != nil {
+		t.Fatalf("error: can't set value: %s", err)
+	}
+	if err := value.Set(" c "); err != nil {
+		t.Fatalf("error: can't set value: %s", err)
+	}
+
+	if value.String() != "a,b,c" {
+		t.Fatalf("error: wrong list %q", value.String())
+	}
+}
+
+func TestValidateOriginalPolicy(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	cfg.App.OriginalPolicy = []string{OriginalOldest, OriginalShortestPath}
+	if err = cfg.validateOriginalPolicy(); err != nil {
+		t.Fatalf("error: valid policies not accepted: %s", err)
+	}
+
+	cfg.App.OriginalPolicy = []string{OriginalPreferred}
+	if err = cfg.validateOriginalPolicy(); err == nil {
+		t.Fatal("error: policy preferred accepted without preferred directories")
+	}
+
+	cfg.App.OriginalPolicy = []string{"unknown"}
+	if err = cfg.validateOriginalPolicy(); err == nil {
+		t.Fatal("error: unknown policy accepted")
+	}
+}
+
+func TestValidateProtectedPaths(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	cfg.App.ProtectedPaths = []string{"/data/photos", "*.jpg"}
+	if err = cfg.validateProtectedPaths(); err != nil {
+		t.Fatalf("error: valid globs not accepted: %s", err)
+	}
+
+	cfg.App.ProtectedPaths = []string{"[a-"}
+	if err = cfg.validateProtectedPaths(); err == nil {
+		t.Fatal("error: wrong glob accepted")
+	}
+}
+
+func TestValidateSourcePaths(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	cfg.App.SourcePaths = []string{"/mnt/nas", "/home/user", "/home/user2"}
+	if err = cfg.validateSourcePaths(); err != nil {
+		t.Fatalf("error: valid source directories not accepted: %s", err)
+	}
+	if paths := cfg.GetSourcePaths(); len(paths) != 3 || paths[0] != "/mnt/nas" {
+		t.Fatalf("error: wrong source directories: %v", paths)
+	}
+
+	cfg.App.SourcePaths = []string{"/home/user", "/home/user/photos"}
+	if err = cfg.validateSourcePaths(); err == nil {
+		t.Fatal("error: nested source directories accepted")
+	}
+
+	cfg.App.SourcePaths = nil
+	if paths := cfg.GetSourcePaths(); len(paths) != 1 || paths[0] != cfg.App.SourcePath {
+		t.Fatalf("error: source directory not used: %v", paths)
+	}
+
+	cfg.App.SourcePaths = []string{"/mnt/import"}
+	cfg.App.ReferencePaths = []string{"/mnt/archive"}
+	if paths := cfg.GetScanPaths(); len(paths) != 2 || paths[0] != "/mnt/archive" {
+		t.Fatalf("error: wrong directories for scan: %v", paths)
+	}
+	cfg.App.ReferencePaths = []string{"/mnt"}
+	if err = cfg.validateSourcePaths(); err == nil {
+		t.Fatal("error: reference directory with source directory accepted")
+	}
+}
+
+func TestListValueWithoutSplit(t *testing.T) {
+	var list []string
+	value := newListValue(&list, false)
+
+	if err := value.Set(`re:^a{1,3}$`); err != nil {
+		t.Fatalf("error: can't set value: %s", err)
+	}
+
+	if len(list) != 1 || list[0] != `re:^a{1,3}$` {
+		t.Fatalf("error: wrong list %q", list)
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	cfg.App.Include = []string{"*.jpg", `re:\.png$`}
+	cfg.App.Exclude = []string{".git/", "node_modules"}
+	if err = cfg.validateFilters(); err != nil {
+		t.Fatalf("error: valid patterns not accepted: %s", err)
+	}
+
+	cfg.App.Exclude = []string{"re:("}
+	if err = cfg.validateFilters(); err == nil {
+		t.Fatal("error: wrong pattern accepted")
+	}
+}
+
+func TestValidateCountGoroutine(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	if err = cfg.validateCountGoroutine(); err != nil {
+		t.Fatalf("error: count of goroutines from configuration not accepted: %s", err)
+	}
+	for _, count := range []int{0, -1} {
+		cfg.App.CountGoroutine = count
+		if err = cfg.validateCountGoroutine(); err == nil {
+			t.Fatalf("error: count of goroutines %d accepted", count)
+		}
+	}
+}
+
+func TestValidateCandidateFilters(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	if cfg.GetMinSize() != 1 {
+		t.Fatalf("error: empty files aren't skipped by default, min size %d", cfg.GetMinSize())
+	}
+	// configuration of library user without min size skip empty files too
+	var emptyCfg Config
+	if emptyCfg.GetMinSize() != 1 {
+		t.Fatalf("error: empty files aren't skipped without min size, min size %d", emptyCfg.GetMinSize())
+	}
+
+	// explicit zero in configuration file don't replace by default
+	dir := t.TempDir()
+	if err = ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte("app:\n  minSize: 0\n"), 0644); err != nil {
+		t.Fatalf("error: can't write configuration file: %s", err)
+	}
+	var zeroCfg Config
+	if err = fig.Load(&zeroCfg, fig.Dirs(dir), fig.File("config.yaml")); err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+	if zeroCfg.GetMinSize() != 0 {
+		t.Fatalf("error: explicit zero min size replaced by %d", zeroCfg.GetMinSize())
+	}
+
+	cfg.App.MaxSize = 1024
+	cfg.App.ModifiedBefore = "2022-01-02"
+	cfg.App.ModifiedAfter = "2021-01-02T15:04:05Z"
+	cfg.App.MimeTypes = []string{"image/*", "text/plain"}
+	if err = cfg.validateCandidateFilters(); err != nil {
+		t.Fatalf("error: valid filters not accepted: %s", err)
+	}
+
+	cfg.SetMinSize(2048)
+	if err = cfg.validateCandidateFilters(); err == nil {
+		t.Fatal("error: min size more than max size accepted")
+	}
+	cfg.SetMinSize(1)
+
+	cfg.App.ModifiedBefore = "yesterday"
+	if err = cfg.validateCandidateFilters(); err == nil {
+		t.Fatal("error: wrong date accepted")
+	}
+	cfg.App.ModifiedBefore = ""
+
+	cfg.App.MimeTypes = []string{"image"}
+	if err = cfg.validateCandidateFilters(); err == nil {
+		t.Fatal("error: wrong MIME type accepted")
+	}
+}
+
+func TestValidateResolveModeDirs(t *testing.T) {
+	cfg, err := Init()
+	if err != nil {
+		t.Fatalf("error: can't load configuration: %s", err)
+	}
+
+	cfg.App.FlagDirs = true
+	cfg.App.FlagDelete = true
+	if err = cfg.validateResolveMode(); err != nil {
+		t.Fatalf("error: delete of duplicate directories not accepted: %s", err)
+	}
+
+	cfg.App.FlagDelete = false
+	cfg.App.LinkMode = LinkHard
+	if err = cfg.validateResolveMode(); err == nil {
+		t.Fatal("error: link of duplicate directories accepted")
+	}
+}
@@ -0,0 +1,223 @@
+package filework
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// hashCache struct for persistent cache of file hashes, key of cache is path to file, hashes of every algorithm save in own bucket
+type hashCache struct {
+	db          *bolt.DB
+	bucket      []byte // bucket of current hash algorithm
+	partialSize int    // size of head and tail of file for partial hash
+}
+
+// cacheEntry struct for save file information in cache, entry is valid while size, modification time and inode of file don't change,
+// partial hash is valid only for same size of head and tail
+type cacheEntry struct {
+	Size        int64  `json:"size"`
+	ModTime     int64  `json:"mtime"`
+	Inode       uint64 `json:"inode"`
+	Hash        string `json:"hash"`
+	PartialHash string `json:"partialHash"`
+	PartialSize int    `json:"partialSize"`
+}
+
+// newCacheEntry function initialize new cacheEntry from FileEntity and size of partial hash, return cacheEntry
+func newCacheEntry(f FileEntity, partialSize int) cacheEntry {
+	return cacheEntry{
+		Size:        f.Size,
+		ModTime:     f.Create.UnixNano(),
+		Inode:       f.Inode,
+		Hash:        f.Hash,
+		PartialHash: f.PartialHash,
+		PartialSize: partialSize,
+	}
+}
+
+// isValid method check entry is actual for file, return bool
+func (e cacheEntry) isValid(f FileEntity) bool {
+	return e.Size == f.Size && e.ModTime == f.Create.UnixNano() && e.Inode == f.Inode
+}
+
+// openHashCache function open file of hash cache, create it if not exist, return *hashCache and error
+func openHashCache(cfg *config.Config) (*hashCache, error) {
+	path, err := cfg.GetCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	// wait a little if another run use cache
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := []byte(cfg.App.HashAlgorithmName)
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	cfg.App.Logger.With(zap.String("cache", path), zap.String("algorithm", cfg.App.HashAlgorithmName)).Debug("Open hash cache.")
+
+	return &hashCache{db: db, bucket: bucket, partialSize: cfg.App.SizePartialHash}, nil
+}
+
+// close method close file of hash cache, nil cache do nothing, return error
+func (c *hashCache) close() error {
+	if c == nil {
+		return nil
+	}
+
+	return c.db.Close()
+}
+
+// load method set hashes from cache to file if entry is valid, nil cache do nothing, return bool
+func (c *hashCache) load(f *FileEntity) bool {
+	if c == nil {
+		return false
+	}
+
+	var entry cacheEntry
+	found := false
+	_ = c.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(c.bucket).Get([]byte(f.Path))
+		if data == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		found = entry.isValid(*f)
+		return nil
+	})
+	if !found {
+		return false
+	}
+
+	f.Hash = entry.Hash
+	// partial hash of other size of head and tail can't be compared with new partial hashes
+	if entry.PartialSize == c.partialSize {
+		f.PartialHash = entry.PartialHash
+	}
+
+	return true
+}
+
+// store method save hashes of files in cache by one transaction, files without hashes skip, nil cache do nothing, return error
+func (c *hashCache) store(files []FileEntity) error {
+	if c == nil {
+		return nil
+	}
+
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(c.bucket)
+		for _, file := range files {
+			if file.Hash == "" && file.PartialHash == "" {
+				continue
+			}
+			data, err := json.Marshal(newCacheEntry(file, c.partialSize))
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put([]byte(file.Path), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// prune method delete stale entries of all hash algorithms from cache: file don't exist or it was changed, return count of deleted entries and error
+func (c *hashCache) prune() (int, error) {
+	count := 0
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			var stale [][]byte
+			err := bucket.ForEach(func(k, v []byte) error {
+				var entry cacheEntry
+				if err := json.Unmarshal(v, &entry); err != nil {
+					stale = append(stale, k)
+					return nil
+				}
+
+				info, err := os.Lstat(string(k))
+				if err != nil {
+					stale = append(stale, k)
+					return nil
+				}
+				f := FileEntity{Create: info.ModTime(), Size: info.Size()}
+				_, f.Inode = getFileID(info)
+				if !entry.isValid(f) {
+					stale = append(stale, k)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			// bucket can't change while iterate
+			for _, k := range stale {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+			count += len(stale)
+			return nil
+		})
+	})
+
+	return count, err
+}
+
+// PruneCache function delete stale entries from hash cache, return error
+func PruneCache(cfg *config.Config, ctx context.Context) error {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "PruneCache")
+	defer span.Finish()
+
+	cache, err := openHashCache(cfg)
+	if err != nil {
+		cfg.App.Logger.Error("Error on open hash cache.",
+			zap.Error(err),
+		)
+		return err
+	}
+	defer func() {
+		if err := cache.close(); err != nil {
+			cfg.App.Logger.Error("Error on close hash cache.",
+				zap.Error(err),
+			)
+		}
+	}()
+
+	count, err := cache.prune()
+	if err != nil {
+		cfg.App.Logger.Error("Error on prune hash cache.",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	fmt.Printf("Deleted stale entries from hash cache: %d\n", count)
+
+	return nil
+}
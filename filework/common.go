@@ -4,14 +4,19 @@
 package filework
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"github.com/White-AK111/fileworker/config"
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
+	"hash"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -23,13 +28,28 @@ type workerPool struct {
 	mu            sync.Mutex
 }
 
+// duplicateGroup struct for save one original file and all it's copies
+type duplicateGroup struct {
+	Original   FileEntity   // original file
+	Duplicates []FileEntity // copies of original file
+	Protected  []FileEntity // protected copies of original file, it's never resolved
+	Directory  bool         // group of duplicate directories, directory resolved by one action
+}
+
 // filesInfo struct for temporary save result of work
 type filesInfo struct {
-	allFilesList       []FileEntity // list with all files
-	duplicateFilesList []FileEntity // list with duplicate files
-	deleteFilesList    []FileEntity // list with deleted files
-	randomFilesList    []FileEntity // list with random create files
-	directoryList      []string
+	allFilesList          []FileEntity     // list with all files
+	duplicateGroups       []duplicateGroup // groups of duplicate files
+	directoryGroups       []duplicateGroup // groups of duplicate directories, only top-most directories
+	resolvedFilesList     []FileEntity     // list with deleted or linked files
+	resolvedDirectoryList []FileEntity     // list with deleted directories
+	skippedFilesList      []FileEntity     // list with files which can't resolve
+	failedFilesList       []FileEntity     // list with files and directories which resolve failed by errors
+	collisionFilesList    []FileEntity     // list with files which have same hash with original, but different content
+	randomFilesList       []FileEntity     // list with random create files
+	linkedGroups          [][]FileEntity   // groups of paths of one file, linked by hard links
+	directoryList         []string
+	reclaimedBytes        int64 // count of bytes reclaimed by resolve of duplicates
 }
 
 // newWorkerPool method initialize new WorkerPool, return *workerPool
@@ -48,11 +68,15 @@ type FileEntity struct {
 	Name         string      // name of file
 	Path         string      // path to file with name of file
 	Hash         string      // hash of file
+	PartialHash  string      // hash of head and tail of file
 	Size         int64       // size of file
+	Device       uint64      // device of file
+	Inode        uint64      // inode of file
+	Links        uint64      // count of hard links of file
 }
 
-// getHashOfFile method get hash of file, return error
-func (f *FileEntity) getHashOfFile(cfg *config.Config, ctx context.Context) error {
+// getHashOfFile method get hash of file by hash of worker, return error
+func (f *FileEntity) getHashOfFile(cfg *config.Config, h hash.Hash, ctx context.Context) error {
 	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "getHashOfFile")
 	defer span.Finish()
 
@@ -63,27 +87,56 @@ func (f *FileEntity) getHashOfFile(cfg *config.Config, ctx context.Context) erro
 	defer fileClose(cfg, file, ctx)
 	cfg.App.Logger.With(zap.String("file", f.Path)).Debug("Open file for get hash.")
 
-	cfg.App.HashAlgorithm.Reset()
-	if _, err := io.Copy(cfg.App.HashAlgorithm, file); err != nil {
+	h.Reset()
+	if _, err := io.Copy(h, file); err != nil {
 		return err
 	}
 
-	hashInBytes := cfg.App.HashAlgorithm.Sum(nil)
+	hashInBytes := h.Sum(nil)
 	f.Hash = hex.EncodeToString(hashInBytes)
 	cfg.App.Logger.With(zap.String("file", f.Path), zap.String("hash", f.Hash)).Debug("Get file hash.")
 
 	return nil
 }
 
-// contains method check contains file in slice of file from i position, return bool
-func (f *FileEntity) contains(fl []FileEntity, it int) bool {
-	for i := it + 1; i < len(fl); i++ {
-		if fl[i].Hash == f.Hash {
-			f.OriginalFile = &fl[i]
-			return true
+// getPartialHashOfFile method get hash of head and tail of file by hash of worker, small files hash fully, return error
+func (f *FileEntity) getPartialHashOfFile(cfg *config.Config, h hash.Hash, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "getPartialHashOfFile")
+	defer span.Finish()
+
+	// head and tail overlap, partial hash is a full hash of file
+	partSize := int64(cfg.App.SizePartialHash)
+	if f.Size <= 2*partSize {
+		if err := f.getHashOfFile(cfg, h, ctx); err != nil {
+			return err
 		}
+		f.PartialHash = f.Hash
+		return nil
+	}
+
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer fileClose(cfg, file, ctx)
+	cfg.App.Logger.With(zap.String("file", f.Path)).Debug("Open file for get partial hash.")
+
+	h.Reset()
+	if _, err := io.CopyN(h, file, partSize); err != nil {
+		return err
 	}
-	return false
+	if _, err := file.Seek(-partSize, io.SeekEnd); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(h, file, partSize); err != nil {
+		return err
+	}
+
+	hashInBytes := h.Sum(nil)
+	f.PartialHash = hex.EncodeToString(hashInBytes)
+	cfg.App.Logger.With(zap.String("file", f.Path), zap.String("hash", f.PartialHash)).Debug("Get partial file hash.")
+
+	return nil
 }
 
 // newFileEntity method initialize new FileEntity, return *FileEntity
@@ -97,88 +150,6 @@ func newFileEntity() *FileEntity {
 	}
 }
 
-// findAllFiles function find all files in source directory without directories, save files info in filesInfo struct, return error
-func findAllFiles(cfg *config.Config, fInfo *filesInfo, ctx context.Context) error {
-	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "findAllFiles")
-	defer span.Finish()
-
-	wp := newWorkerPool(cfg.App.CountGoroutine)
-	defer wp.wg.Wait()
-
-	wp.wg.Add(1)
-	lsFiles(cfg.App.SourcePath, cfg, wp, fInfo, ctx)
-
-	return nil
-}
-
-// lsFiles recursive function for find files in all directories
-func lsFiles(dir string, cfg *config.Config, wp *workerPool, fInfo *filesInfo, ctx context.Context) {
-	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "lsFiles")
-	defer span.Finish()
-
-	// block while full
-	wp.semaphoreChan <- struct{}{}
-
-	go func() {
-		defer catchRecover(cfg, ctx)
-		defer func() {
-			wp.mu.Unlock()
-			// read to release a slot
-			<-wp.semaphoreChan
-			wp.wg.Done()
-		}()
-
-		wp.mu.Lock()
-		cfg.App.Logger.With(zap.String("directory", dir)).Debug("Open directory.")
-		file, err := os.Open(dir)
-		if err != nil {
-			cfg.App.Logger.Error("Error opening directory",
-				zap.String("directory", dir),
-				zap.Error(err),
-			)
-		}
-
-		defer fileClose(cfg, file, ctx)
-
-		// loads all children files into memory
-		files, err := file.Readdir(-1)
-		if err != nil {
-			cfg.App.Logger.Error("Error reading directory",
-				zap.String("directory", dir),
-				zap.Error(err),
-			)
-		}
-
-		for _, f := range files {
-			path := dir + "/" + f.Name()
-			if f.IsDir() {
-				cfg.App.Logger.With(zap.String("directory", path)).Debug("Go to child directory.")
-				fInfo.directoryList = append(fInfo.directoryList, path)
-				wp.wg.Add(1)
-				if cfg.App.DoPanic {
-					panic("Panic!")
-				}
-				go lsFiles(path, cfg, wp, fInfo, ctx)
-			} else {
-				cfg.App.Logger.With(zap.String("file", path)).Debug("Find file in directory.")
-				fe := newFileEntity()
-				fe.Name = f.Name()
-				fe.Path = path
-				fe.Create = f.ModTime()
-				// get hash of file
-				if err = fe.getHashOfFile(cfg, ctx); err != nil {
-					cfg.App.Logger.Error("Can't get hash of file",
-						zap.String("file", path),
-						zap.Error(err),
-					)
-				}
-				fe.Size = f.Size()
-				fInfo.allFilesList = append(fInfo.allFilesList, *fe)
-			}
-		}
-	}()
-}
-
 // fileClose function for defer close file or directory
 func fileClose(cfg *config.Config, file *os.File, ctx context.Context) {
 	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "fileClose")
@@ -226,6 +197,97 @@ func byteCopy(cfg *config.Config, source *os.File, destination *os.File, ctx con
 	return nil
 }
 
+// moveFile function move file to new path, create parent directories, existing file on new path is never overwritten,
+// between devices file copy and delete, return error
+func moveFile(cfg *config.Config, from string, to string, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "moveFile")
+	defer span.Finish()
+
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return err
+	}
+
+	// rename replace existing file, link fails if new path exist, so check and create of new path is one operation
+	cfg.App.Logger.With(zap.String("source", from), zap.String("destination", to)).Debug("Move file.")
+	err := os.Link(from, to)
+	if err == nil {
+		return os.Remove(from)
+	}
+	if !errors.Is(err, syscall.EXDEV) && !errors.Is(err, syscall.EPERM) && !errors.Is(err, syscall.ENOTSUP) {
+		return err
+	}
+
+	// link can't cross devices or isn't supported by file system, copy file with permissions and modification time
+	info, err := os.Stat(from)
+	if err != nil {
+		return err
+	}
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer fileClose(cfg, source, ctx)
+
+	destination, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if err = byteCopy(cfg, source, destination, ctx); err != nil {
+		fileClose(cfg, destination, ctx)
+		_ = os.Remove(to)
+		return err
+	}
+	if err = destination.Close(); err != nil {
+		_ = os.Remove(to)
+		return err
+	}
+	if err = os.Chtimes(to, info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Remove(from)
+}
+
+// compareFiles function compare content of two files byte by byte by use buffer, return bool and error
+func compareFiles(cfg *config.Config, pathA string, pathB string, ctx context.Context) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "compareFiles")
+	defer span.Finish()
+
+	fileA, err := os.Open(pathA)
+	if err != nil {
+		return false, err
+	}
+	defer fileClose(cfg, fileA, ctx)
+
+	fileB, err := os.Open(pathB)
+	if err != nil {
+		return false, err
+	}
+	defer fileClose(cfg, fileB, ctx)
+
+	cfg.App.Logger.With(zap.String("file", pathA), zap.String("another file", pathB)).Debug("Compare files byte by byte.")
+	bufA := make([]byte, cfg.App.SizeCopyBuffer)
+	bufB := make([]byte, cfg.App.SizeCopyBuffer)
+	for {
+		nA, errA := io.ReadFull(fileA, bufA)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return false, errA
+		}
+		nB, errB := io.ReadFull(fileB, bufB)
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		// equal parts and end of one file, it's end of both files
+		if errA != nil {
+			return true, nil
+		}
+	}
+}
+
 // catchRecover func for do recover in another functions
 func catchRecover(cfg *config.Config, ctx context.Context) {
 	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "catchRecover")
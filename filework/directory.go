@@ -0,0 +1,437 @@
+package filework
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// errDirectoryChanged error for directories which content differ from content on find of duplicates
+var errDirectoryChanged = errors.New("content of directory changed")
+
+// dirNode struct for save tree hash of one directory
+type dirNode struct {
+	hash      string // tree hash of directory, empty for directory which can't be compared
+	size      int64  // size of all files in directory and subdirectories
+	protected bool   // directory contains protected files
+}
+
+// writeDirEntry function write entry of directory to tree hash: names quoted, files with size and hash of content,
+// subdirectories with tree hash
+func writeDirEntry(w io.Writer, name string, isDir bool, size int64, hash string) {
+	if isDir {
+		fmt.Fprintf(w, "d %q %s\n", name, hash)
+		return
+	}
+	fmt.Fprintf(w, "f %q %d %s\n", name, size, hash)
+}
+
+// findDuplicateDirs function find directories with same names and content of files by Merkle-style tree hash: hash of directory
+// get from names and hashes of files and hashes of subdirectories. Directory with files without full hash (unique files), with
+// skipped files, symbolic links or without files can't be duplicate. Only top-most duplicate directories are returned,
+// return []duplicateGroup
+func findDuplicateDirs(cfg *config.Config, directories []string, files []FileEntity, ctx context.Context) []duplicateGroup {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "findDuplicateDirs")
+	defer span.Finish()
+
+	filesByDir := make(map[string]map[string]FileEntity)
+	for _, file := range files {
+		dir := filepath.Dir(file.Path)
+		if filesByDir[dir] == nil {
+			filesByDir[dir] = make(map[string]FileEntity)
+		}
+		filesByDir[dir][filepath.Base(file.Path)] = file
+	}
+
+	// subdirectories before parents
+	dirs := append([]string(nil), directories...)
+	sort.Slice(dirs, func(i, j int) bool {
+		return getPathDepth(dirs[i]) > getPathDepth(dirs[j])
+	})
+	nodes := make(map[string]*dirNode, len(dirs))
+	for _, dir := range dirs {
+		if _, ok := nodes[dir]; !ok {
+			nodes[dir] = newDirNode(cfg, dir, filesByDir[dir], nodes)
+		}
+	}
+
+	// source directories can't be deleted
+	roots := make(map[string]bool)
+	for _, root := range cfg.GetScanPaths() {
+		roots[root] = true
+	}
+	hashGroups := make(map[string][]FileEntity)
+	for dir, node := range nodes {
+		if node.hash == "" || roots[dir] {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			continue
+		}
+		fe := FileEntity{Name: filepath.Base(dir), Path: dir, Create: info.ModTime(), Size: node.size, Hash: node.hash}
+		fe.Device, fe.Inode = getFileID(info)
+		hashGroups[node.hash] = append(hashGroups[node.hash], fe)
+	}
+
+	duplicated := make(map[string]bool)
+	for _, group := range hashGroups {
+		if len(group) > 1 {
+			for _, dir := range group {
+				duplicated[dir.Path] = true
+			}
+		}
+	}
+
+	compares := getOriginalCompares(cfg)
+	var groups []duplicateGroup
+	for _, group := range hashGroups {
+		// directory in duplicate parent is resolved with parent
+		var topMost []FileEntity
+		for _, dir := range group {
+			if !duplicated[filepath.Dir(dir.Path)] {
+				topMost = append(topMost, dir)
+			}
+		}
+		if len(topMost) < 2 {
+			continue
+		}
+
+		var dg duplicateGroup
+		if len(cfg.App.ReferencePaths) > 0 {
+			var ok bool
+			if dg, ok = selectReferenceOriginal(cfg.App.ReferencePaths, topMost, compares); !ok {
+				continue
+			}
+		} else {
+			dg = selectOriginal(topMost, compares)
+		}
+
+		dg.Directory = true
+		// directory with protected files can't be deleted by one action
+		duplicates := dg.Duplicates
+		dg.Duplicates = nil
+		for _, dir := range duplicates {
+			if nodes[dir.Path].protected || isProtected(cfg.App.ProtectedPaths, dir.Path) {
+				dg.Protected = append(dg.Protected, dir)
+			} else {
+				dg.Duplicates = append(dg.Duplicates, dir)
+			}
+		}
+		groups = append(groups, dg)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Original.Path < groups[j].Original.Path
+	})
+
+	cfg.App.Logger.Debug("Find duplicate directories.",
+		zap.Int("directories", len(nodes)),
+		zap.Int("groups", len(groups)),
+	)
+
+	return groups
+}
+
+// newDirNode function get tree hash of directory from hashes of files and tree hashes of subdirectories, every entry of directory
+// must be file with full hash or compared subdirectory, return *dirNode
+func newDirNode(cfg *config.Config, dir string, files map[string]FileEntity, nodes map[string]*dirNode) *dirNode {
+	node := &dirNode{}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		cfg.App.Logger.Warn("Can't read directory for compare.",
+			zap.String("directory", dir),
+			zap.Error(err),
+		)
+		return node
+	}
+
+	h := cfg.App.NewHash()
+	countFiles := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case entry.Mode().IsRegular():
+			file, ok := files[entry.Name()]
+			if !ok || file.Hash == "" {
+				return &dirNode{}
+			}
+			writeDirEntry(h, entry.Name(), false, file.Size, file.Hash)
+			node.size += file.Size
+			node.protected = node.protected || isProtected(cfg.App.ProtectedPaths, path)
+			countFiles++
+		case entry.IsDir():
+			child, ok := nodes[path]
+			if !ok || child.hash == "" {
+				return &dirNode{}
+			}
+			writeDirEntry(h, entry.Name(), true, child.size, child.hash)
+			node.size += child.size
+			node.protected = node.protected || child.protected
+			countFiles++
+		default:
+			return &dirNode{}
+		}
+	}
+	if countFiles == 0 {
+		return &dirNode{}
+	}
+	node.hash = hex.EncodeToString(h.Sum(nil))
+
+	return node
+}
+
+// hashDirectory function get tree hash of directory with hash of content of every file, directory with symbolic links
+// or special files can't be hashed, return tree hash, size of files and error
+func hashDirectory(cfg *config.Config, h hash.Hash, dir string, ctx context.Context) (string, int64, error) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "hashDirectory")
+	defer span.Finish()
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", 0, err
+	}
+
+	tree := cfg.App.NewHash()
+	var size int64
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case entry.Mode().IsRegular():
+			file := FileEntity{Path: path, Size: entry.Size()}
+			if err = file.getHashOfFile(cfg, h, ctx); err != nil {
+				return "", 0, err
+			}
+			writeDirEntry(tree, entry.Name(), false, file.Size, file.Hash)
+			size += file.Size
+		case entry.IsDir():
+			subHash, subSize, err := hashDirectory(cfg, h, path, ctx)
+			if err != nil {
+				return "", 0, err
+			}
+			writeDirEntry(tree, entry.Name(), true, subSize, subHash)
+			size += subSize
+		default:
+			return "", 0, fmt.Errorf("%w: directory contains not regular file %s", errDirectoryChanged, path)
+		}
+	}
+
+	return hex.EncodeToString(tree.Sum(nil)), size, nil
+}
+
+// excludeDirFiles function exclude files in duplicate directories, it's resolved with directory, return []FileEntity
+func excludeDirFiles(groups []duplicateGroup, files []FileEntity) []FileEntity {
+	if len(groups) == 0 {
+		return files
+	}
+
+	dirs := make(map[string]bool)
+	for _, group := range groups {
+		for _, dir := range group.Duplicates {
+			dirs[dir.Path] = true
+		}
+	}
+
+	result := make([]FileEntity, 0, len(files))
+	for _, file := range files {
+		if !isInDirs(dirs, file.Path) {
+			result = append(result, file)
+		}
+	}
+
+	return result
+}
+
+// keepDirOriginals function keep files in original directories of duplicate directories: file of original directory become original
+// of group of duplicate files, other files of original directories are never resolved, return []duplicateGroup
+func keepDirOriginals(dirGroups []duplicateGroup, groups []duplicateGroup) []duplicateGroup {
+	if len(dirGroups) == 0 {
+		return groups
+	}
+
+	dirs := make(map[string]bool)
+	for _, group := range dirGroups {
+		dirs[group.Original.Path] = true
+	}
+
+	for i := range groups {
+		group := &groups[i]
+		var kept, duplicates []FileEntity
+		for _, file := range group.Duplicates {
+			if isInDirs(dirs, file.Path) {
+				kept = append(kept, file)
+			} else {
+				duplicates = append(duplicates, file)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		if !isInDirs(dirs, group.Original.Path) {
+			duplicates = append(duplicates, group.Original)
+			group.Original, kept = kept[0], kept[1:]
+		}
+
+		sort.Slice(duplicates, func(i, j int) bool {
+			return duplicates[i].Path < duplicates[j].Path
+		})
+		group.Duplicates = duplicates
+		group.Protected = append(group.Protected, kept...)
+	}
+
+	return groups
+}
+
+// isInDirs function check path placed in one of directories or its subdirectories, return bool
+func isInDirs(dirs map[string]bool, path string) bool {
+	for p := filepath.Dir(path); ; p = filepath.Dir(p) {
+		if dirs[p] {
+			return true
+		}
+		if filepath.Dir(p) == p {
+			return false
+		}
+	}
+}
+
+// resolveDirectories function delete duplicate directories by one action for every directory, before delete content of directory
+// and original directory compare with content on find of duplicates, every action write to journal, return error
+func resolveDirectories(cfg *config.Config, fInfo *filesInfo, action resolveAction, j *journal, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "resolveDirectories")
+	defer span.Finish()
+
+	h := cfg.App.NewHash()
+	countFailed := 0
+	for _, group := range fInfo.directoryGroups {
+		for _, dir := range group.Duplicates {
+			reference := isReference(cfg.App.ReferencePaths, dir.Path)
+			if reference || isProtected(cfg.App.ProtectedPaths, dir.Path) {
+				message := "Directory is protected, directory skipped."
+				if reference {
+					message = "Directory is in reference directory, directory skipped."
+				}
+				cfg.App.Logger.Warn(message,
+					zap.String("action", action.name),
+					zap.String("directory", dir.Path),
+				)
+				fInfo.skippedFilesList = append(fInfo.skippedFilesList, dir)
+				continue
+			}
+
+			reclaimed, err := deleteDirectory(cfg, h, dir, group.Original, j, ctx)
+			if errors.Is(err, errDirectoryChanged) {
+				cfg.App.Logger.Warn("Directory skipped.",
+					zap.String("action", action.name),
+					zap.String("directory", dir.Path),
+					zap.String("original", group.Original.Path),
+					zap.Error(err),
+				)
+				fInfo.skippedFilesList = append(fInfo.skippedFilesList, dir)
+				continue
+			}
+			if err != nil {
+				cfg.App.Logger.Error("Error on resolve directory.",
+					zap.String("action", action.name),
+					zap.String("directory", dir.Path),
+					zap.Error(err),
+				)
+				fInfo.failedFilesList = append(fInfo.failedFilesList, dir)
+				countFailed++
+				continue
+			}
+			if err = j.done(actionDeleteDir, dir.Path); err != nil {
+				cfg.App.Logger.Error("Error on write journal.",
+					zap.String("action", action.name),
+					zap.String("directory", dir.Path),
+					zap.Error(err),
+				)
+			}
+
+			fInfo.resolvedDirectoryList = append(fInfo.resolvedDirectoryList, dir)
+			fInfo.reclaimedBytes += reclaimed
+		}
+	}
+
+	if countFailed > 0 {
+		return fmt.Errorf("%w: %d directories aren't %s", errResolveFailed, countFailed, action.done)
+	}
+
+	return nil
+}
+
+// deleteDirectory function delete duplicate directory with all files by one action, directory and original directory
+// which changed after find of duplicates are skipped, return count of reclaimed bytes and error
+func deleteDirectory(cfg *config.Config, h hash.Hash, dir FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "deleteDirectory")
+	defer span.Finish()
+
+	// all files of directory are deleted at once, don't trust hashes of scan
+	for _, d := range []FileEntity{original, dir} {
+		treeHash, _, err := hashDirectory(cfg, h, d.Path, ctx)
+		if err != nil {
+			return 0, err
+		}
+		if treeHash != dir.Hash {
+			return 0, fmt.Errorf("%w: %s", errDirectoryChanged, d.Path)
+		}
+	}
+
+	// files linked from other places stay on disk after delete
+	reclaimed, err := getDirReclaimSize(dir.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = writeJournal(j, actionDeleteDir, dir, original, ""); err != nil {
+		return 0, err
+	}
+
+	cfg.App.Logger.With(zap.String("directory", dir.Path), zap.String("original", original.Path)).Debug("Delete directory.")
+	if err = os.RemoveAll(dir.Path); err != nil {
+		return 0, err
+	}
+
+	return reclaimed, nil
+}
+
+// getDirReclaimSize function get count of bytes which delete of directory reclaim: file with hard links count once and only
+// if all it's links are in directory, like in getReclaimSize, return int64 and error
+func getDirReclaimSize(dir string) (int64, error) {
+	var size int64
+	links := make(map[fileKey]uint64)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file := FileEntity{Size: info.Size(), Links: getFileLinks(info)}
+		file.Device, file.Inode = getFileID(info)
+		if file.Links <= 1 || file.Inode == 0 {
+			size += getReclaimSize(file)
+			return nil
+		}
+		// last link of file in directory reclaim space of file
+		key := fileKey{dev: file.Device, ino: file.Inode}
+		links[key]++
+		if links[key] == file.Links {
+			size += file.Size
+		}
+		return nil
+	})
+
+	return size, err
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/opentracing/opentracing-go"
 	"go.uber.org/zap"
 	"os"
-	"sort"
 	"strings"
 )
 
@@ -17,102 +16,258 @@ func DoDuplicateFiles(cfg *config.Config, ctx context.Context) error {
 	defer span.Finish()
 
 	fInfo := filesInfo{}
-	fInfo.directoryList = append(fInfo.directoryList, cfg.App.SourcePath)
+	fInfo.directoryList = append(fInfo.directoryList, cfg.GetScanPaths()...)
 
 	err := findAllFiles(cfg, &fInfo, ctx)
 	if err != nil {
 		cfg.App.Logger.Error("Error on find all files in source path.",
-			zap.String("path", cfg.App.SourcePath),
+			zap.Strings("path", cfg.GetScanPaths()),
 			zap.Error(err),
 		)
 		return err
 	}
 
-	// sort files, files in root directory priority are considered like original
-	cfg.App.Logger.Debug("Sort files, files in root directory priority are considered like original.")
-	sort.Slice(fInfo.allFilesList, func(i, j int) bool {
-		return fInfo.allFilesList[i].Path < fInfo.allFilesList[j].Path
-	})
+	// open hash cache, without cache all files hash again
+	var cache *hashCache
+	if !cfg.App.FlagNoCache {
+		cache, err = openHashCache(cfg)
+		if err != nil {
+			cfg.App.Logger.Warn("Can't open hash cache, continue without cache.",
+				zap.Error(err),
+			)
+		}
+		defer func() {
+			if err := cache.close(); err != nil {
+				cfg.App.Logger.Error("Error on close hash cache.",
+					zap.Error(err),
+				)
+			}
+		}()
+	}
+
+	// get candidates for duplicates, full hash get only for files with same size and partial hash
+	cfg.App.Logger.Debug("Find candidates for duplicates.")
+	// paths of one file don't compare and don't hash twice, only first path compare with other files
+	files, linkedGroups := groupLinks(fInfo.allFilesList)
+	fInfo.linkedGroups = linkedGroups
+	candidates := findCandidates(cfg, files, cache, ctx)
+
+	// duplicate directory report and resolve as one item, files in it don't report
+	if cfg.App.FlagDirs {
+		cfg.App.Logger.Debug("Find duplicate directories.")
+		fInfo.directoryGroups = findDuplicateDirs(cfg, fInfo.directoryList, candidates, ctx)
+		candidates = excludeDirFiles(fInfo.directoryGroups, candidates)
+	}
+
+	// group files by size and hash
+	cfg.App.Logger.Debug("Group files by size and hash.")
+	fInfo.duplicateGroups = groupDuplicates(cfg, candidates, ctx)
+	fInfo.duplicateGroups = keepDirOriginals(fInfo.directoryGroups, fInfo.duplicateGroups)
+	// every path of duplicate file resolve, one path of file with hard links don't free space
+	fInfo.duplicateGroups, fInfo.linkedGroups = expandLinks(cfg.App.ProtectedPaths, cfg.App.ReferencePaths, fInfo.duplicateGroups, fInfo.linkedGroups)
 
-	// compare files
-	cfg.App.Logger.Debug("Compare files.")
-	for i, file := range fInfo.allFilesList {
-		if file.contains(fInfo.allFilesList, i) {
-			fmt.Printf("Duplicate file: %s	Original file: %s\n", file.Path, file.OriginalFile.Path)
-			fInfo.duplicateFilesList = append(fInfo.duplicateFilesList, file)
+	countDirectories, countProtected := 0, 0
+	for _, group := range fInfo.directoryGroups {
+		for _, dir := range group.Duplicates {
+			fmt.Printf("Duplicate directory: %s	Original directory: %s	Size: %d\n", dir.Path, group.Original.Path, dir.Size)
 		}
+		for _, dir := range group.Protected {
+			fmt.Printf("Protected duplicate directory: %s	Original directory: %s\n", dir.Path, group.Original.Path)
+		}
+		countDirectories += len(group.Duplicates)
+		countProtected += len(group.Protected)
+	}
+
+	countDuplicates := 0
+	for _, group := range fInfo.duplicateGroups {
+		for _, file := range group.Duplicates {
+			fmt.Printf("Duplicate file: %s	Original file: %s\n", file.Path, group.Original.Path)
+		}
+		for _, file := range group.Protected {
+			fmt.Printf("Protected duplicate file: %s	Original file: %s\n", file.Path, group.Original.Path)
+		}
+		countDuplicates += len(group.Duplicates)
+		countProtected += len(group.Protected)
+	}
+
+	countLinked := 0
+	for _, group := range fInfo.linkedGroups {
+		for _, file := range group[1:] {
+			fmt.Printf("Already linked file: %s	Linked with: %s\n", file.Path, group[0].Path)
+		}
+		countLinked += len(group) - 1
 	}
 
 	fmt.Printf("Total files: %d\n", len(fInfo.allFilesList))
-	fmt.Printf("Duplicate files (without original file): %d\n", len(fInfo.duplicateFilesList))
-
-	// delete files if get a flag, get approval from user
-	if cfg.App.FlagDelete || cfg.App.RunInTest {
-		if len(fInfo.duplicateFilesList) == 0 {
-			fmt.Println("No files for delete!")
-		} else {
-			cfg.App.Logger.Debug("Get confirm for delete from user.")
-			var confirm string
-			if !cfg.App.RunInTest {
-				for strings.ToUpper(confirm) != "Y" && strings.ToUpper(confirm) != "N" {
-					fmt.Print("Delete this duplicate files? (Y/N): ")
-					_, err = fmt.Fscan(os.Stdin, &confirm)
-					if err != nil {
-						cfg.App.Logger.Warn("Error on get approval to delete from console.",
-							zap.String("get value", confirm),
-							zap.Error(err),
-						)
-						return err
-					}
-				}
+	fmt.Printf("Duplicate groups: %d\n", len(fInfo.duplicateGroups))
+	fmt.Printf("Duplicate files (without original file): %d\n", countDuplicates)
+	if cfg.App.FlagDirs {
+		fmt.Printf("Duplicate directory groups: %d\n", len(fInfo.directoryGroups))
+		fmt.Printf("Duplicate directories (without original directory): %d\n", countDirectories)
+	}
+	if countLinked > 0 {
+		fmt.Printf("Already linked files (hard links, nothing to reclaim): %d\n", countLinked)
+	}
+	if countProtected > 0 {
+		fmt.Printf("Protected duplicate files (never resolved): %d\n", countProtected)
+	}
+
+	// resolve files if get a flag, get approval from user
+	if needResolve(cfg) {
+		action := getResolveAction(cfg)
+		if countDuplicates == 0 && countDirectories == 0 {
+			fmt.Printf("No files for %s!\n", action.name)
+			return nil
+		}
+
+		// user review every group and choose files for keep, it's approval of resolve
+		if cfg.App.FlagInteractive {
+			groups := append(append([]duplicateGroup(nil), fInfo.directoryGroups...), fInfo.duplicateGroups...)
+			groups, err = reviewGroups(cfg, groups, os.Stdin, os.Stdout, ctx)
+			if err != nil {
+				return err
 			}
-			cfg.App.Logger.Debug("Delete duplicated files.")
-			if strings.ToUpper(confirm) == "Y" || cfg.App.RunInTest {
-				err = deleteFiles(cfg, &fInfo, ctx)
-				if err != nil {
-					cfg.App.Logger.Error("Error on delete files.",
-						zap.Error(err),
-					)
-					return err
+			fInfo.directoryGroups, fInfo.duplicateGroups = nil, nil
+			for _, group := range groups {
+				if group.Directory {
+					fInfo.directoryGroups = append(fInfo.directoryGroups, group)
+				} else {
+					fInfo.duplicateGroups = append(fInfo.duplicateGroups, group)
 				}
-				fmt.Println("Files deleted!")
+			}
+			if len(fInfo.duplicateGroups) == 0 && len(fInfo.directoryGroups) == 0 {
+				fmt.Printf("No files for %s!\n", action.name)
+				return nil
+			}
+		}
+
+		// dry run only save plan of actions, files don't touch
+		if cfg.App.FlagDryRun {
+			return savePlanOfRun(cfg, &fInfo, action, ctx)
+		}
+
+		approved := cfg.App.FlagInteractive
+		if !approved {
+			approved, err = getApproval(cfg, action.question)
+			if err != nil {
+				return err
 			}
 		}
+		if approved {
+			return resolveWithJournal(cfg, &fInfo, action, ctx)
+		}
 	}
 
 	return nil
 }
 
-// deleteFiles function delete files, return error
-func deleteFiles(cfg *config.Config, fInfo *filesInfo, ctx context.Context) error {
-	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "deleteFiles")
+// getApproval function get approval for action from user, in test approval isn't needed, return bool and error
+func getApproval(cfg *config.Config, question string) (bool, error) {
+	if cfg.App.RunInTest {
+		return true, nil
+	}
+
+	cfg.App.Logger.Debug("Get confirm for resolve from user.", zap.String("question", question))
+	var confirm string
+	for strings.ToUpper(confirm) != "Y" && strings.ToUpper(confirm) != "N" {
+		fmt.Printf("%s? (Y/N): ", question)
+		_, err := fmt.Fscan(os.Stdin, &confirm)
+		if err != nil {
+			cfg.App.Logger.Warn("Error on get approval to resolve from console.",
+				zap.String("get value", confirm),
+				zap.Error(err),
+			)
+			return false, err
+		}
+	}
+
+	return strings.ToUpper(confirm) == "Y", nil
+}
+
+// resolveWithJournal function resolve duplicate files by action, every action write to journal before it performed, run can be undone,
+// print result of resolve, return error
+func resolveWithJournal(cfg *config.Config, fInfo *filesInfo, action resolveAction, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "resolveWithJournal")
 	defer span.Finish()
 
-	wp := newWorkerPool(cfg.App.CountGoroutine)
-	defer wp.wg.Wait()
-
-	for _, file := range fInfo.duplicateFilesList {
-		wp.wg.Add(1)
-		go func(file FileEntity) {
-			defer func() {
-				wp.mu.Unlock()
-				// read to release a slot
-				<-wp.semaphoreChan
-				fInfo.deleteFilesList = append(fInfo.deleteFilesList, file)
-				wp.wg.Done()
-			}()
-			// block while full
-			wp.semaphoreChan <- struct{}{}
-			wp.mu.Lock()
-			cfg.App.Logger.With(zap.String("file", file.Path)).Debug("Delete file.")
-			if err := os.Remove(file.Path); err != nil {
-				cfg.App.Logger.Error("Error on delete file.",
-					zap.String("file", file.Path),
-					zap.Error(err),
-				)
-			}
-		}(file)
+	cfg.App.Logger.Debug("Resolve duplicated files.", zap.String("action", action.name))
+	j, err := openJournal(cfg)
+	if err != nil {
+		cfg.App.Logger.Error("Error on create journal.",
+			zap.Error(err),
+		)
+		return err
+	}
+	// failed files don't stop resolve of directories, run report all failures
+	err = resolveFiles(cfg, fInfo, action, j, ctx)
+	if len(fInfo.directoryGroups) > 0 {
+		errDirs := resolveDirectories(cfg, fInfo, action, j, ctx)
+		if err == nil {
+			err = errDirs
+		} else if errDirs != nil {
+			err = fmt.Errorf("%w: %d files and directories aren't %s", errResolveFailed, len(fInfo.failedFilesList), action.done)
+		}
+	}
+	if errClose := j.close(); errClose != nil {
+		cfg.App.Logger.Error("Error on close journal.",
+			zap.String("journal", j.path()),
+			zap.Error(errClose),
+		)
+	}
+
+	printCollisions(fInfo)
+	printSkipped(fInfo)
+	printFailed(fInfo)
+	fmt.Printf("Files %s: %d\n", action.done, len(fInfo.resolvedFilesList))
+	if len(fInfo.resolvedDirectoryList) > 0 {
+		fmt.Printf("Directories %s: %d\n", action.done, len(fInfo.resolvedDirectoryList))
+	}
+	fmt.Printf("Bytes reclaimed: %d\n", fInfo.reclaimedBytes)
+	fmt.Printf("Journal for undo: %s\n", j.path())
+
+	if err != nil {
+		cfg.App.Logger.Error("Error on resolve files.",
+			zap.String("action", action.name),
+			zap.Error(err),
+		)
+		return err
 	}
 
 	return nil
 }
+
+// printCollisions function print files which don't pass byte by byte verify
+func printCollisions(fInfo *filesInfo) {
+	if len(fInfo.collisionFilesList) == 0 {
+		return
+	}
+
+	for _, file := range fInfo.collisionFilesList {
+		fmt.Printf("Hash collision, file skipped: %s	Hash: %s\n", file.Path, file.Hash)
+	}
+	fmt.Printf("Hash collisions: %d\n", len(fInfo.collisionFilesList))
+}
+
+// printSkipped function print files which skipped by resolve action
+func printSkipped(fInfo *filesInfo) {
+	if len(fInfo.skippedFilesList) == 0 {
+		return
+	}
+
+	for _, file := range fInfo.skippedFilesList {
+		fmt.Printf("File skipped: %s\n", file.Path)
+	}
+	fmt.Printf("Skipped files: %d\n", len(fInfo.skippedFilesList))
+}
+
+// printFailed function print files and directories which resolve failed by errors
+func printFailed(fInfo *filesInfo) {
+	if len(fInfo.failedFilesList) == 0 {
+		return
+	}
+
+	for _, file := range fInfo.failedFilesList {
+		fmt.Printf("Failed to resolve: %s\n", file.Path)
+	}
+	fmt.Printf("Failed files: %d\n", len(fInfo.failedFilesList))
+}
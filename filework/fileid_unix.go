@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package filework
+
+import (
+	"os"
+	"syscall"
+)
+
+// getFileID function return device and inode of file, return uint64, uint64
+func getFileID(info os.FileInfo) (uint64, uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino)
+}
+
+// getFileLinks function return count of hard links of file, return uint64
+func getFileLinks(info os.FileInfo) uint64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1
+	}
+
+	return uint64(stat.Nlink)
+}
+
+// getFileOwner function return user and group of owner of file, return int, int
+func getFileOwner(info os.FileInfo) (int, int) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1
+	}
+
+	return int(stat.Uid), int(stat.Gid)
+}
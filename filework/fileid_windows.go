@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package filework
+
+import "os"
+
+// getFileID function return device and inode of file, on windows file system don't give it in os.FileInfo, return uint64, uint64
+func getFileID(info os.FileInfo) (uint64, uint64) {
+	return 0, 0
+}
+
+// getFileLinks function return count of hard links of file, on windows file system don't give it in os.FileInfo, return uint64
+func getFileLinks(info os.FileInfo) uint64 {
+	return 1
+}
+
+// getFileOwner function return user and group of owner of file, on windows file system don't give it in os.FileInfo, return int, int
+func getFileOwner(info os.FileInfo) (int, int) {
+	return -1, -1
+}
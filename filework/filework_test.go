@@ -1,15 +1,62 @@
 package filework
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"github.com/White-AK111/fileworker/config"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
+	"io"
 	"io/ioutil"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
+// copyTestFiles helper copy TestFiles directory to temporary directory, tests don't change original test files, return path to copy
+func copyTestFiles(tb testing.TB) string {
+	tb.Helper()
+
+	dst := tb.TempDir()
+	err := filepath.Walk("../TestFiles", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel("../TestFiles", path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		source, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		destination, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer destination.Close()
+		_, err = io.Copy(destination, source)
+		return err
+	})
+	if err != nil {
+		tb.Fatalf("error on copy test files: %s", err)
+	}
+
+	return dst
+}
+
 // TestDoDuplicateFiles test for DoDuplicateFiles function
 func TestDoDuplicateFiles(t *testing.T) {
 	cfg, err := config.Init()
@@ -17,22 +64,1402 @@ func TestDoDuplicateFiles(t *testing.T) {
 		t.Fatalf("error on load configuration file: %s", err)
 	}
 
-	cfg.App.FlagDelete = true
-	cfg.App.SourcePath = "../TestFiles"
+	cfg.App.FlagDelete = true
+	cfg.App.SourcePath = copyTestFiles(t)
+	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
+	cfg.App.JournalPath = t.TempDir()
+
+	filesB, _ := ioutil.ReadDir(cfg.App.SourcePath)
+
+	ctx := context.Background()
+
+	err = DoDuplicateFiles(cfg, ctx)
+	if err != nil {
+		t.Fatalf("error on duplicate files function: %s", err)
+	}
+
+	filesA, _ := ioutil.ReadDir(cfg.App.SourcePath)
+
+	assert.NotEqual(t, filesB, filesA, "count of file don't changes, before: %d, after: %d", len(filesB), len(filesA))
+}
+
+// TestFindCandidates test for findCandidates function, files with unique size don't hash
+func TestFindCandidates(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = copyTestFiles(t)
+	cfg.App.SizePartialHash = 2
+
+	ctx := context.Background()
+
+	fInfo := filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, ctx); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+
+	candidates := findCandidates(cfg, fInfo.allFilesList, nil, ctx)
+
+	// file6.txt and file7.txt have the same size as other files, but unique content, empty files file4.txt skipped by min size
+	assert.Len(t, candidates, 14)
+	for _, file := range candidates {
+		assert.NotEmpty(t, file.Hash, "file %s don't have hash", file.Path)
+		assert.NotEqual(t, "file6.txt", file.Name)
+		assert.NotEqual(t, "file7.txt", file.Name)
+	}
+}
+
+// TestFindAllFilesFilter test for findAllFiles function with include and exclude patterns, excluded directories don't walk
+func TestFindAllFilesFilter(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = copyTestFiles(t)
+	// count empty files too
+	cfg.SetMinSize(0)
+
+	tests := []struct {
+		include []string
+		exclude []string
+		count   int
+	}{
+		{count: 18},
+		{exclude: []string{"SubFiles/"}, count: 13},
+		{exclude: []string{"file1*.txt"}, count: 14},
+		{include: []string{"AnotherSubFiles/"}, count: 3},
+		{include: []string{"**/SubSubFiles/*.txt"}, count: 3},
+		{include: []string{`re:file[0-9]\.txt$`}, exclude: []string{"/file9.txt"}, count: 15},
+	}
+
+	for _, test := range tests {
+		cfg.App.Include = test.include
+		cfg.App.Exclude = test.exclude
+
+		fInfo := filesInfo{}
+		if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+			t.Fatalf("error on find all files: %s", err)
+		}
+		assert.Len(t, fInfo.allFilesList, test.count, "include %v, exclude %v", test.include, test.exclude)
+		if len(test.exclude) > 0 && test.exclude[0] == "SubFiles/" {
+			assert.NotContains(t, fInfo.directoryList, filepath.Join(cfg.App.SourcePath, "SubFiles", "SubSubFiles"))
+		}
+	}
+}
+
+// TestFindAllFilesIgnoreFiles test for findAllFiles function with rules of ignore files, rules of deeper directory override rules of parent
+func TestFindAllFilesIgnoreFiles(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = t.TempDir()
+	files := map[string]string{
+		".gitignore":                 "*.log\n!keep.log\nbuild/\n",
+		"a.log":                      "ignored",
+		"keep.log":                   "keep",
+		"a.txt":                      "keep",
+		"build/b.txt":                "ignored",
+		"sub/.ignore":                "*.tmp\n",
+		"sub/.fileworkerignore":      "!x.tmp\n",
+		"sub/x.tmp":                  "keep",
+		"sub/y.tmp":                  "ignored",
+		"sub/c.log":                  "ignored",
+		"sub/inner/.gitignore":       "keep.log\n",
+		"sub/inner/keep.log":         "ignored",
+		"sub/inner/d.tmp":            "ignored",
+		"sub/inner/wrong/.gitignore": "file[0-9\n",
+		"sub/inner/wrong/e.tmp":      "ignored",
+	}
+	for name, content := range files {
+		path := filepath.Join(cfg.App.SourcePath, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error on create directory: %s", err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+
+	fInfo := filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	assert.Len(t, fInfo.allFilesList, len(files))
+
+	cfg.App.FlagIgnoreFiles = true
+	fInfo = filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	var found []string
+	for _, file := range fInfo.allFilesList {
+		rel, _ := filepath.Rel(cfg.App.SourcePath, file.Path)
+		found = append(found, filepath.ToSlash(rel))
+		assert.NotEqual(t, "ignored", files[filepath.ToSlash(rel)], "file %s isn't ignored", rel)
+	}
+	assert.ElementsMatch(t, []string{".gitignore", "keep.log", "a.txt", "sub/.ignore", "sub/.fileworkerignore", "sub/x.tmp", "sub/inner/.gitignore", "sub/inner/wrong/.gitignore"}, found)
+}
+
+// TestFindAllFilesCandidateFilter test for findAllFiles function with filters of size, modification time, extension and MIME type
+func TestFindAllFilesCandidateFilter(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = t.TempDir()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	files := map[string][]byte{
+		"empty.txt": {},
+		"small.txt": []byte("small"),
+		"big.txt":   []byte("big text file content"),
+		"image.PNG": png,
+		"image.dat": png,
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(cfg.App.SourcePath, name), content, 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err = os.Chtimes(filepath.Join(cfg.App.SourcePath, "small.txt"), old, old); err != nil {
+		t.Fatalf("error on change time of file: %s", err)
+	}
+
+	tests := []struct {
+		setup func()
+		found []string
+	}{
+		{setup: func() {}, found: []string{"small.txt", "big.txt", "image.PNG", "image.dat"}},
+		{setup: func() { cfg.SetMinSize(0) }, found: []string{"empty.txt", "small.txt", "big.txt", "image.PNG", "image.dat"}},
+		{setup: func() { cfg.App.MaxSize = 10 }, found: []string{"small.txt"}},
+		{setup: func() { cfg.App.ModifiedBefore = time.Now().Add(-24 * time.Hour).Format(time.RFC3339) }, found: []string{"small.txt"}},
+		{setup: func() { cfg.App.ModifiedAfter = time.Now().Add(-24 * time.Hour).Format("2006-01-02") }, found: []string{"big.txt", "image.PNG", "image.dat"}},
+		{setup: func() { cfg.App.Extensions = []string{"png", ".txt"} }, found: []string{"small.txt", "big.txt", "image.PNG"}},
+		{setup: func() { cfg.App.MimeTypes = []string{"image/*"} }, found: []string{"image.PNG", "image.dat"}},
+	}
+
+	for _, test := range tests {
+		cfg.SetMinSize(1)
+		cfg.App.MaxSize = 0
+		cfg.App.ModifiedBefore = ""
+		cfg.App.ModifiedAfter = ""
+		cfg.App.Extensions = nil
+		cfg.App.MimeTypes = nil
+		test.setup()
+
+		fInfo := filesInfo{}
+		if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+			t.Fatalf("error on find all files: %s", err)
+		}
+		var found []string
+		for _, file := range fInfo.allFilesList {
+			found = append(found, file.Name)
+		}
+		assert.ElementsMatch(t, test.found, found)
+	}
+}
+
+// TestFindAllFilesSymlinks test for findAllFiles function with policies of symbolic links: skipped links, followed links with loops,
+// file found by link and by own path is found once, non-regular files are skipped
+func TestFindAllFilesSymlinks(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = t.TempDir()
+	external, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatalf("error on get path of directory: %s", err)
+	}
+	files := map[string]string{
+		filepath.Join(cfg.App.SourcePath, "a.txt"):        "a",
+		filepath.Join(cfg.App.SourcePath, "sub", "b.txt"): "b",
+		filepath.Join(external, "dir", "c.txt"):           "c",
+		filepath.Join(external, "d.txt"):                  "d",
+	}
+	for path, content := range files {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error on create directory: %s", err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+
+	links := map[string]string{
+		"loop":            cfg.App.SourcePath,
+		"sub/up":          "..",
+		"outside":         filepath.Join(external, "dir"),
+		"link.txt":        filepath.Join("sub", "b.txt"),
+		"external.txt":    filepath.Join(external, "d.txt"),
+		"broken.txt":      "missing.txt",
+		"sub/outside.txt": filepath.Join(external, "dir", "c.txt"),
+	}
+	for name, target := range links {
+		if err = os.Symlink(target, filepath.Join(cfg.App.SourcePath, filepath.FromSlash(name))); err != nil {
+			t.Skipf("symbolic links aren't supported: %s", err)
+		}
+	}
+	// socket is non-regular file
+	if listener, err := net.Listen("unix", filepath.Join(cfg.App.SourcePath, "socket")); err == nil {
+		defer listener.Close()
+	}
+
+	tests := []struct {
+		policy  string
+		exclude []string
+		found   []string
+	}{
+		{policy: config.SymlinksSkip, found: []string{
+			filepath.Join(cfg.App.SourcePath, "a.txt"),
+			filepath.Join(cfg.App.SourcePath, "sub", "b.txt"),
+		}},
+		// targets outside of source directory are skipped
+		{policy: config.SymlinksFollow, found: []string{
+			filepath.Join(cfg.App.SourcePath, "a.txt"),
+			filepath.Join(cfg.App.SourcePath, "sub", "b.txt"),
+		}},
+		// filters match path of target, not path of link
+		{policy: config.SymlinksFollow, exclude: []string{"/sub/"}, found: []string{
+			filepath.Join(cfg.App.SourcePath, "a.txt"),
+		}},
+	}
+
+	for _, test := range tests {
+		cfg.App.SymlinkPolicy = test.policy
+		cfg.App.Exclude = test.exclude
+		fInfo := filesInfo{}
+		if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+			t.Fatalf("error on find all files: %s", err)
+		}
+		var found []string
+		for _, file := range fInfo.allFilesList {
+			found = append(found, file.Path)
+		}
+		assert.ElementsMatch(t, test.found, found, "policy %s", test.policy)
+	}
+
+	// duplicate outside of source directory isn't deleted
+	if err = ioutil.WriteFile(filepath.Join(external, "d.txt"), []byte("a"), 0644); err != nil {
+		t.Fatalf("error on create file: %s", err)
+	}
+	cfg.App.SymlinkPolicy = config.SymlinksFollow
+	cfg.App.Exclude = nil
+	cfg.App.FlagDelete = true
+	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
+	cfg.App.JournalPath = t.TempDir()
+	if err = DoDuplicateFiles(cfg, context.Background()); err != nil {
+		t.Fatalf("error on duplicate files function: %s", err)
+	}
+	assert.FileExists(t, filepath.Join(external, "d.txt"))
+	assert.FileExists(t, filepath.Join(cfg.App.SourcePath, "a.txt"))
+}
+
+// TestFindAllFilesSourcePaths test for DoDuplicateFiles function with several source directories, duplicates found across directories,
+// file from first source directory is original, files in quarantine placed by number and name of source directory
+func TestFindAllFilesSourcePaths(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	nas, home := filepath.Join(t.TempDir(), "nas"), filepath.Join(t.TempDir(), "home")
+	files := map[string]string{
+		filepath.Join(nas, "photo.jpg"):          "photo",
+		filepath.Join(home, "a", "photo.jpg"):    "photo",
+		filepath.Join(home, "b", "copy.jpg"):     "photo",
+		filepath.Join(home, "unique.txt"):        "unique",
+		filepath.Join(nas, "docs", "report.txt"): "report",
+		filepath.Join(home, "report.txt"):        "report",
+	}
+	// files have same modification time, except one
+	now := time.Now()
+	for path, content := range files {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error on create directory: %s", err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+		if err = os.Chtimes(path, now, now); err != nil {
+			t.Fatalf("error on change time of file: %s", err)
+		}
+	}
+	// oldest file in home directory, but original is file from first source directory
+	old := now.Add(-time.Hour)
+	if err = os.Chtimes(filepath.Join(home, "b", "copy.jpg"), old, old); err != nil {
+		t.Fatalf("error on change time of file: %s", err)
+	}
+
+	cfg.App.SourcePaths = []string{nas, home}
+	cfg.App.FlagNoCache = true
+	fInfo := filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	assert.Len(t, fInfo.allFilesList, len(files))
+
+	groups := groupDuplicates(cfg, findCandidates(cfg, fInfo.allFilesList, nil, context.Background()), context.Background())
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, filepath.Join(nas, "docs", "report.txt"), groups[0].Original.Path)
+		assert.Equal(t, filepath.Join(nas, "photo.jpg"), groups[1].Original.Path)
+		assert.Len(t, groups[1].Duplicates, 2)
+	}
+
+	// policy oldest before policy root
+	cfg.App.OriginalPolicy = []string{config.OriginalOldest, config.OriginalRoot}
+	groups = groupDuplicates(cfg, findCandidates(cfg, fInfo.allFilesList, nil, context.Background()), context.Background())
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, filepath.Join(home, "b", "copy.jpg"), groups[1].Original.Path)
+	}
+
+	cfg.App.OriginalPolicy = nil
+	cfg.App.QuarantinePath = t.TempDir()
+	cfg.App.JournalPath = t.TempDir()
+	cfg.App.RunInTest = true
+	if err = DoDuplicateFiles(cfg, context.Background()); err != nil {
+		t.Fatalf("error on duplicate files: %s", err)
+	}
+	for _, path := range []string{filepath.Join("2-home", "a", "photo.jpg"), filepath.Join("2-home", "b", "copy.jpg"), filepath.Join("2-home", "report.txt")} {
+		_, err = os.Stat(filepath.Join(cfg.App.QuarantinePath, path))
+		assert.NoError(t, err)
+	}
+}
+
+// TestQuarantineSameNames test for DoDuplicateFiles function with quarantine and source directories with same names, paths in quarantine
+// don't collide, file already placed in quarantine is never overwritten
+func TestQuarantineSameNames(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	first, second := filepath.Join(t.TempDir(), "data"), filepath.Join(t.TempDir(), "data")
+	files := map[string]string{
+		filepath.Join(first, "original.txt"): "photo",
+		filepath.Join(first, "a.txt"):        "photo",
+		filepath.Join(second, "a.txt"):       "photo",
+		filepath.Join(second, "b.txt"):       "photo",
+	}
+	for path, content := range files {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error on create directory: %s", err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+	// file from previous run stay in quarantine
+	cfg.App.QuarantinePath = t.TempDir()
+	busy := filepath.Join(cfg.App.QuarantinePath, "2-data", "b.txt")
+	if err = os.MkdirAll(filepath.Dir(busy), 0755); err != nil {
+		t.Fatalf("error on create directory: %s", err)
+	}
+	if err = ioutil.WriteFile(busy, []byte("previous"), 0644); err != nil {
+		t.Fatalf("error on create file: %s", err)
+	}
+	previous := quarantineEntry{Path: filepath.Join(second, "b.txt"), Quarantine: busy, Time: time.Now()}
+	if err = newQuarantine(cfg.App.QuarantinePath).writeEntry(previous); err != nil {
+		t.Fatalf("error on write manifest of quarantine: %s", err)
+	}
+
+	cfg.App.SourcePaths = []string{first, second}
+	cfg.App.FlagNoCache = true
+	cfg.App.JournalPath = t.TempDir()
+	cfg.App.RunInTest = true
+	if err = DoDuplicateFiles(cfg, context.Background()); err != nil {
+		t.Fatalf("error on duplicate files: %s", err)
+	}
+
+	for _, path := range []string{filepath.Join("1-data", "a.txt"), filepath.Join("2-data", "a.txt")} {
+		content, err := ioutil.ReadFile(filepath.Join(cfg.App.QuarantinePath, path))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "photo", string(content))
+		}
+	}
+	content, err := ioutil.ReadFile(busy)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "previous", string(content))
+	}
+	assert.FileExists(t, filepath.Join(second, "b.txt"))
+
+	// entry of skipped file is removed, entry of file moved before stay
+	entries, err := readQuarantineManifest(cfg.App.QuarantinePath)
+	if err != nil {
+		t.Fatalf("error on read manifest of quarantine: %s", err)
+	}
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, busy, entries[0].Quarantine)
+		assert.NotEqual(t, busy, entries[1].Quarantine)
+		assert.NotEqual(t, busy, entries[2].Quarantine)
+	}
+}
+
+// TestReferencePaths test for DoDuplicateFiles function with reference directory, only files of source directory which duplicate
+// files of reference are deleted, duplicates inside reference and inside source directory are ignored
+func TestReferencePaths(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	archive, imported := filepath.Join(t.TempDir(), "archive"), filepath.Join(t.TempDir(), "import")
+	files := map[string]string{
+		filepath.Join(archive, "a.jpg"):         "a",
+		filepath.Join(archive, "old", "a.jpg"):  "a",
+		filepath.Join(archive, "b.jpg"):         "b",
+		filepath.Join(imported, "a.jpg"):        "a",
+		filepath.Join(imported, "new", "b.jpg"): "b",
+		filepath.Join(imported, "c.jpg"):        "c",
+		filepath.Join(imported, "c_copy.jpg"):   "c",
+	}
+	for path, content := range files {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error on create directory: %s", err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+
+	cfg.App.SourcePath = imported
+	cfg.App.ReferencePaths = []string{archive}
+	cfg.App.FlagNoCache = true
+	fInfo := filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	assert.Len(t, fInfo.allFilesList, len(files))
+
+	groups := groupDuplicates(cfg, findCandidates(cfg, fInfo.allFilesList, nil, context.Background()), context.Background())
+	if assert.Len(t, groups, 2) {
+		for _, group := range groups {
+			assert.True(t, isReference(cfg.App.ReferencePaths, group.Original.Path))
+			if assert.Len(t, group.Duplicates, 1) {
+				assert.False(t, isReference(cfg.App.ReferencePaths, group.Duplicates[0].Path))
+			}
+		}
+	}
+
+	cfg.App.FlagDelete = true
+	cfg.App.JournalPath = t.TempDir()
+	cfg.App.RunInTest = true
+	if err = DoDuplicateFiles(cfg, context.Background()); err != nil {
+		t.Fatalf("error on duplicate files: %s", err)
+	}
+	for path := range files {
+		_, err = os.Stat(path)
+		deleted := path == filepath.Join(imported, "a.jpg") || path == filepath.Join(imported, "new", "b.jpg")
+		assert.Equal(t, deleted, os.IsNotExist(err), "file %s", path)
+	}
+}
+
+// TestHashFiles test for hashFiles function, workers with own hash give same hashes as one worker
+func TestHashFiles(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = copyTestFiles(t)
+
+	ctx := context.Background()
+
+	fInfo := filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, ctx); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+
+	one := make([]FileEntity, len(fInfo.allFilesList))
+	copy(one, fInfo.allFilesList)
+	cfg.App.CountGoroutine = 1
+	one = hashFiles(cfg, one, false, ctx)
+
+	cfg.App.CountGoroutine = 8
+	many := hashFiles(cfg, fInfo.allFilesList, false, ctx)
+
+	assert.Len(t, many, len(fInfo.allFilesList))
+	assert.Equal(t, one, many)
+}
+
+// TestHashCache test for hash cache, unchanged files load hash from cache, changed files hash again
+func TestHashCache(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = copyTestFiles(t)
+	cfg.App.CachePath = filepath.Join(t.TempDir(), "cache.db")
+
+	ctx := context.Background()
+
+	cache, err := openHashCache(cfg)
+	if err != nil {
+		t.Fatalf("error on open hash cache: %s", err)
+	}
+	defer cache.close()
+
+	fInfo := filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, ctx); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	candidates := findCandidates(cfg, fInfo.allFilesList, cache, ctx)
+
+	file := candidates[0]
+	file.Hash, file.PartialHash = "", ""
+	assert.True(t, cache.load(&file), "hash of file %s don't load from cache", file.Path)
+	assert.Equal(t, candidates[0].Hash, file.Hash)
+	assert.Equal(t, candidates[0].PartialHash, file.PartialHash)
+
+	// partial hash of other size of head and tail don't load
+	cache.partialSize = cfg.App.SizePartialHash * 2
+	file.Hash, file.PartialHash = "", ""
+	assert.True(t, cache.load(&file), "hash of file %s don't load from cache", file.Path)
+	assert.Equal(t, candidates[0].Hash, file.Hash)
+	assert.Empty(t, file.PartialHash)
+
+	// change of size invalidate entry
+	file.Size++
+	assert.False(t, cache.load(&file), "hash of changed file %s load from cache", file.Path)
+
+	// entry of deleted file is stale
+	if err = os.Remove(file.Path); err != nil {
+		t.Fatalf("error on delete file: %s", err)
+	}
+	count, err := cache.prune()
+	if err != nil {
+		t.Fatalf("error on prune hash cache: %s", err)
+	}
+	assert.Equal(t, 1, count)
+}
+
+// TestGroupDuplicates test for groupDuplicates function, every group has one original and its copies
+func TestGroupDuplicates(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	files := []FileEntity{
+		{Name: "a.txt", Path: "/a/a.txt", Hash: "h1", Size: 1},
+		{Name: "a.txt", Path: "/b/a.txt", Hash: "h1", Size: 1},
+		{Name: "c.txt", Path: "/c/c.txt", Hash: "h1", Size: 1},
+		{Name: "d.txt", Path: "/d/d.txt", Hash: "h2", Size: 1},
+		{Name: "e.txt", Path: "/e/e.txt", Hash: "h2", Size: 2},
+	}
+
+	groups := groupDuplicates(cfg, files, context.Background())
+
+	assert.Len(t, groups, 1)
+	assert.Equal(t, "/c/c.txt", groups[0].Original.Path)
+	assert.Len(t, groups[0].Duplicates, 2)
+}
+
+// TestSelectOriginal test for selectOriginal function, policies select original and next policy break ties of previous
+func TestSelectOriginal(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	files := []FileEntity{
+		{Name: "copy of a.txt", Path: "/data/backup/old/copy of a.txt", Create: old.Add(time.Minute)},
+		{Name: "a.txt", Path: "/data/photos/a.txt", Create: old.Add(time.Hour)},
+		{Name: "a.txt", Path: "/data/a.txt", Create: old},
+		{Name: "b.txt", Path: "/data/z/b.txt", Create: old.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		policy    []string
+		preferred []string
+		original  string
+	}{
+		{policy: nil, original: "/data/z/b.txt"},
+		{policy: []string{config.OriginalOldest}, original: "/data/a.txt"},
+		{policy: []string{config.OriginalNewest}, original: "/data/z/b.txt"},
+		{policy: []string{config.OriginalNewest, config.OriginalShortestPath}, original: "/data/z/b.txt"},
+		{policy: []string{config.OriginalShortestPath}, original: "/data/a.txt"},
+		{policy: []string{config.OriginalShallowest}, original: "/data/a.txt"},
+		{policy: []string{config.OriginalLongestName}, original: "/data/backup/old/copy of a.txt"},
+		{policy: []string{config.OriginalPreferred}, preferred: []string{"/data/photos"}, original: "/data/photos/a.txt"},
+		{policy: []string{config.OriginalPreferred, config.OriginalOldest}, preferred: []string{"/data/z", "/data/backup"}, original: "/data/z/b.txt"},
+		{policy: []string{config.OriginalPreferred, config.OriginalOldest}, preferred: []string{"/data/none"}, original: "/data/a.txt"},
+	}
+
+	for _, test := range tests {
+		cfg.App.OriginalPolicy = test.policy
+		cfg.App.PreferredPaths = test.preferred
+
+		group := make([]FileEntity, len(files))
+		copy(group, files)
+		dg := selectOriginal(group, getOriginalCompares(cfg))
+
+		assert.Equal(t, test.original, dg.Original.Path, "policy %v", test.policy)
+		assert.Len(t, dg.Duplicates, len(files)-1)
+		for _, file := range dg.Duplicates {
+			assert.NotEqual(t, dg.Original.Path, file.Path)
+		}
+	}
+}
+
+// TestProtectedPaths test for protected paths, protected file is original, protected copies and groups with all protected files don't resolve
+func TestProtectedPaths(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	assert.True(t, isProtected([]string{"/data/photos"}, "/data/photos/2020/a.jpg"))
+	assert.True(t, isProtected([]string{"/data/*/2020"}, "/data/photos/2020/a.jpg"))
+	assert.True(t, isProtected([]string{"*.jpg"}, "/data/photos/2020/a.jpg"))
+	assert.True(t, isProtected([]string{".git"}, "/data/src/.git/objects/a"))
+	assert.False(t, isProtected([]string{"/data/photos"}, "/data/photos2/a.jpg"))
+	assert.False(t, isProtected([]string{"*.png"}, "/data/photos/a.jpg"))
+
+	cfg.App.ProtectedPaths = []string{"/keep"}
+	files := []FileEntity{
+		{Name: "a.txt", Path: "/a/a.txt", Hash: "h1", Size: 1},
+		{Name: "a.txt", Path: "/keep/a.txt", Hash: "h1", Size: 1},
+		{Name: "z.txt", Path: "/z/z.txt", Hash: "h1", Size: 1},
+		{Name: "b.txt", Path: "/keep/b.txt", Hash: "h2", Size: 1},
+		{Name: "b.txt", Path: "/keep/sub/b.txt", Hash: "h2", Size: 1},
+	}
+
+	groups := groupDuplicates(cfg, files, context.Background())
+	if !assert.Len(t, groups, 2) {
+		return
+	}
+	assert.Equal(t, "/keep/a.txt", groups[0].Original.Path)
+	assert.Len(t, groups[0].Duplicates, 2)
+	assert.Empty(t, groups[0].Protected)
+	assert.Equal(t, "/keep/sub/b.txt", groups[1].Original.Path)
+	assert.Empty(t, groups[1].Duplicates)
+	assert.Len(t, groups[1].Protected, 1)
+
+	// resolve never touch protected file, even if it's in list of duplicates
+	protectedFiles := writeTestFiles(t, "original", "original")
+	cfg.App.ProtectedPaths = []string{protectedFiles[1].Path}
+	cfg.App.FlagDelete = true
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: protectedFiles[0], Duplicates: protectedFiles[1:]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), nil, context.Background()); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+	assert.FileExists(t, protectedFiles[1].Path)
+	assert.Len(t, fInfo.skippedFilesList, 1)
+	assert.Empty(t, fInfo.resolvedFilesList)
+}
+
+// TestReviewGroups test for reviewGroups function driven by scripted input
+func TestReviewGroups(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	var groups []duplicateGroup
+	for i := 1; i <= 5; i++ {
+		groups = append(groups, duplicateGroup{
+			Original:   FileEntity{Path: fmt.Sprintf("/b/%d.txt", i), Size: 1},
+			Duplicates: []FileEntity{{Path: fmt.Sprintf("/a/%d.txt", i), Size: 1}},
+		})
+	}
+	groups = append(groups[:2], append([]duplicateGroup{{Original: FileEntity{Path: "/keep/p.txt"}, Protected: []FileEntity{{Path: "/keep/q.txt"}}}}, groups[2:]...)...)
+
+	// keep second file, keep original, wrong choice and skip, keep originals in all remaining groups
+	out := &bytes.Buffer{}
+	reviewed, err := reviewGroups(cfg, groups, strings.NewReader("2\n\n9\ns\na\n"), out, context.Background())
+	if err != nil {
+		t.Fatalf("error on review groups: %s", err)
+	}
+	if !assert.Len(t, reviewed, 4) {
+		return
+	}
+	assert.Equal(t, "/a/1.txt", reviewed[0].Original.Path)
+	assert.Equal(t, "/b/1.txt", reviewed[0].Duplicates[0].Path)
+	assert.Equal(t, "/b/2.txt", reviewed[1].Original.Path)
+	assert.Equal(t, "/b/4.txt", reviewed[2].Original.Path)
+	assert.Equal(t, "/b/5.txt", reviewed[3].Original.Path)
+	assert.Contains(t, out.String(), "Wrong choice")
+	assert.NotContains(t, out.String(), "/keep/p.txt")
+	// group with only protected files don't count in numbers
+	assert.Contains(t, out.String(), "Group 3/5")
+	assert.NotContains(t, out.String(), "/6")
+
+	// skip all remaining groups and end of input
+	reviewed, err = reviewGroups(cfg, groups, strings.NewReader("\nq\n"), &bytes.Buffer{}, context.Background())
+	if err != nil {
+		t.Fatalf("error on review groups: %s", err)
+	}
+	assert.Len(t, reviewed, 1)
+	reviewed, err = reviewGroups(cfg, groups, strings.NewReader("1\n"), &bytes.Buffer{}, context.Background())
+	if err != nil {
+		t.Fatalf("error on review groups: %s", err)
+	}
+	assert.Len(t, reviewed, 1)
+}
+
+// TestGroupLinks test for groupLinks and expandLinks functions, paths of one file compare once, remove of file with other hard links
+// don't reclaim space, all paths of duplicate file are resolved together
+func TestGroupLinks(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err = ioutil.WriteFile(filepath.Join(cfg.App.SourcePath, name), []byte("same content"), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+	if err = os.Link(filepath.Join(cfg.App.SourcePath, "a.txt"), filepath.Join(cfg.App.SourcePath, "c.txt")); err != nil {
+		t.Skipf("hard links aren't supported: %s", err)
+	}
+
+	fInfo := filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	assert.Len(t, fInfo.allFilesList, 3)
+
+	files, linked := groupLinks(fInfo.allFilesList)
+	if assert.Len(t, linked, 1) && assert.Len(t, linked[0], 2) {
+		assert.Equal(t, filepath.Join(cfg.App.SourcePath, "a.txt"), linked[0][0].Path)
+		assert.Equal(t, filepath.Join(cfg.App.SourcePath, "c.txt"), linked[0][1].Path)
+		assert.Equal(t, uint64(2), linked[0][0].Links)
+	}
+	assert.Len(t, files, 2)
+
+	cfg.App.FlagNoCache = true
+	candidates := findCandidates(cfg, files, nil, context.Background())
+	groups := groupDuplicates(cfg, candidates, context.Background())
+	if assert.Len(t, groups, 1) {
+		assert.Len(t, groups[0].Duplicates, 1)
+	}
+
+	for _, file := range files {
+		if file.Name == "a.txt" {
+			assert.Equal(t, int64(0), getReclaimSize(file))
+		} else {
+			assert.Equal(t, file.Size, getReclaimSize(file))
+		}
+	}
+
+	// all paths of duplicate resolve together and reclaim space of file once
+	groups, linked = expandLinks(nil, nil, groups, linked)
+	assert.Empty(t, linked)
+	if assert.Len(t, groups, 1) && assert.Len(t, groups[0].Duplicates, 2) {
+		assert.Equal(t, filepath.Join(cfg.App.SourcePath, "b.txt"), groups[0].Original.Path)
+		var reclaimed int64
+		for _, file := range groups[0].Duplicates {
+			reclaimed += getReclaimSize(file)
+		}
+		assert.Equal(t, groups[0].Original.Size, reclaimed)
+	}
+
+	cfg.App.FlagDelete = true
+	cfg.App.RunInTest = true
+	cfg.App.JournalPath = t.TempDir()
+	if err = DoDuplicateFiles(cfg, context.Background()); err != nil {
+		t.Fatalf("error on duplicate files function: %s", err)
+	}
+	assert.NoFileExists(t, filepath.Join(cfg.App.SourcePath, "a.txt"))
+	assert.NoFileExists(t, filepath.Join(cfg.App.SourcePath, "c.txt"))
+	assert.FileExists(t, filepath.Join(cfg.App.SourcePath, "b.txt"))
+
+	// path in reference directory isn't resolved and keep content of file
+	reference := filepath.Join(string(filepath.Separator), "reference")
+	duplicate := FileEntity{Path: filepath.Join(string(filepath.Separator), "source", "a.txt"), Size: 5, Links: 2}
+	link := FileEntity{Path: filepath.Join(reference, "z.txt"), Size: 5, Links: 2}
+	groups = []duplicateGroup{{Original: FileEntity{Path: filepath.Join(reference, "o.txt"), Size: 5}, Duplicates: []FileEntity{duplicate}}}
+	groups, linked = expandLinks(nil, []string{reference}, groups, [][]FileEntity{{duplicate, link}})
+	assert.Empty(t, linked)
+	assert.Empty(t, groups[0].Protected)
+	if assert.Len(t, groups[0].Duplicates, 1) {
+		assert.Equal(t, duplicate.Path, groups[0].Duplicates[0].Path)
+		assert.Equal(t, int64(0), getReclaimSize(groups[0].Duplicates[0]))
+	}
+}
+
+// TestGroupDuplicatesVerify test for groupDuplicates function, files with same non-cryptographic hash and different content split
+func TestGroupDuplicatesVerify(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.HashAlgorithmName = "crc32"
+
+	dir := t.TempDir()
+	var files []FileEntity
+	for i, content := range []string{"collision", "collision", "different"} {
+		path := filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on write file: %s", err)
+		}
+		// simulate collision of hash
+		files = append(files, FileEntity{Name: filepath.Base(path), Path: path, Hash: "h1", Size: 9})
+	}
+
+	groups := groupDuplicates(cfg, files, context.Background())
+
+	assert.Len(t, groups, 1)
+	assert.Len(t, groups[0].Duplicates, 1)
+	assert.NotEqual(t, files[2].Path, groups[0].Original.Path)
+
+	// without verify collision is a duplicate
+	cfg.App.FlagNoVerifyHash = true
+	groups = groupDuplicates(cfg, files, context.Background())
+	assert.Len(t, groups[0].Duplicates, 2)
+}
+
+// TestDeleteFilesVerify test for resolveFiles function with verify, file with different content don't delete
+func TestDeleteFilesVerify(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.FlagVerify = true
+
+	dir := t.TempDir()
+	var files []FileEntity
+	for i, content := range []string{"original", "original", "collision"} {
+		path := filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on write file: %s", err)
+		}
+		files = append(files, FileEntity{Name: filepath.Base(path), Path: path, Hash: "h1", Size: 8})
+	}
+
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[1:]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), nil, context.Background()); err != nil {
+		t.Fatalf("error on delete files: %s", err)
+	}
+
+	assert.NoFileExists(t, files[1].Path)
+	assert.FileExists(t, files[2].Path)
+	assert.Len(t, fInfo.resolvedFilesList, 1)
+	assert.Len(t, fInfo.collisionFilesList, 1)
+}
+
+// writeTestFiles helper write files with content to temporary directory, return []FileEntity
+func writeTestFiles(t *testing.T, contents ...string) []FileEntity {
+	t.Helper()
+
+	dir := t.TempDir()
+	var files []FileEntity
+	for i, content := range contents {
+		path := filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on write file: %s", err)
+		}
+		files = append(files, FileEntity{Name: filepath.Base(path), Path: path, Hash: "h1", Size: int64(len(content))})
+	}
+
+	return files
+}
+
+// TestHardLinkFile test for resolveFiles function with hard links, duplicate replace by link, file with other permissions skip
+func TestHardLinkFile(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.LinkMode = config.LinkHard
+	cfg.App.FlagPreserveAttrs = true
+
+	files := writeTestFiles(t, "original", "original", "original")
+	if err = os.Chmod(files[2].Path, 0600); err != nil {
+		t.Fatalf("error on change permissions of file: %s", err)
+	}
+
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[1:]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), nil, context.Background()); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+
+	originalInfo, _ := os.Stat(files[0].Path)
+	linkInfo, _ := os.Stat(files[1].Path)
+	skippedInfo, _ := os.Stat(files[2].Path)
+	assert.True(t, os.SameFile(originalInfo, linkInfo), "file %s isn't hard link to original", files[1].Path)
+	assert.False(t, os.SameFile(originalInfo, skippedInfo), "file %s with other permissions is hard link to original", files[2].Path)
+	assert.Len(t, fInfo.resolvedFilesList, 1)
+	assert.Len(t, fInfo.skippedFilesList, 1)
+}
+
+// TestSymLinkFile test for resolveFiles function with symbolic links, duplicate replace by relative link, next scan skip link
+func TestSymLinkFile(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.LinkMode = config.LinkSym
+	cfg.App.LinkTarget = config.LinkTargetRelative
+
+	files := writeTestFiles(t, "original", "original")
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[1:]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), nil, context.Background()); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+
+	target, err := os.Readlink(files[1].Path)
+	if err != nil {
+		t.Fatalf("error on read symbolic link: %s", err)
+	}
+	assert.Equal(t, files[0].Name, target)
+
+	cfg.App.SourcePath = filepath.Dir(files[0].Path)
+	fInfo = filesInfo{}
+	if err = findAllFiles(cfg, &fInfo, context.Background()); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	assert.Len(t, fInfo.allFilesList, 1)
+}
+
+// TestReflinkFile test for resolveFiles function with reflinks, file system without reflinks skip files, files stay independent
+func TestReflinkFile(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.LinkMode = config.LinkReflink
+
+	files := writeTestFiles(t, "original", "original")
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[1:]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), nil, context.Background()); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+
+	// result depend on file system of temporary directory
+	assert.Equal(t, 1, len(fInfo.resolvedFilesList)+len(fInfo.skippedFilesList))
+	if len(fInfo.resolvedFilesList) == 1 {
+		assert.Equal(t, files[1].Size, fInfo.reclaimedBytes)
+	}
+
+	originalInfo, _ := os.Stat(files[0].Path)
+	fileInfo, _ := os.Stat(files[1].Path)
+	assert.False(t, os.SameFile(originalInfo, fileInfo), "file %s isn't independent of original", files[1].Path)
+	content, _ := ioutil.ReadFile(files[1].Path)
+	assert.Equal(t, "original", string(content))
+}
+
+// TestQuarantine test for DoDuplicateFiles function with quarantine directory and RestoreQuarantine function, files move to quarantine and back
+func TestQuarantine(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = copyTestFiles(t)
+	cfg.App.QuarantinePath = filepath.Join(cfg.App.SourcePath, "quarantine")
 	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
+	cfg.App.JournalPath = t.TempDir()
 
-	filesB, _ := ioutil.ReadDir(cfg.App.SourcePath)
+	ctx := context.Background()
+
+	duplicate := filepath.Join(cfg.App.SourcePath, "AnotherSubFiles", "file1.txt")
+	if err = DoDuplicateFiles(cfg, ctx); err != nil {
+		t.Fatalf("error on duplicate files function: %s", err)
+	}
+	assert.NoFileExists(t, duplicate)
+	assert.FileExists(t, filepath.Join(cfg.App.QuarantinePath, "AnotherSubFiles", "file1.txt"))
+
+	entries, err := readQuarantineManifest(cfg.App.QuarantinePath)
+	if err != nil {
+		t.Fatalf("error on read manifest of quarantine: %s", err)
+	}
+	assert.Len(t, entries, 7)
+
+	// quarantine directory in source directory don't scan
+	if err = DoDuplicateFiles(cfg, ctx); err != nil {
+		t.Fatalf("error on duplicate files function: %s", err)
+	}
+	entries, _ = readQuarantineManifest(cfg.App.QuarantinePath)
+	assert.Len(t, entries, 7)
+
+	if err = RestoreQuarantine(cfg, cfg.App.QuarantinePath, ctx); err != nil {
+		t.Fatalf("error on restore files from quarantine: %s", err)
+	}
+	assert.FileExists(t, duplicate)
+	entries, _ = readQuarantineManifest(cfg.App.QuarantinePath)
+	assert.Empty(t, entries)
+}
+
+// TestResolveFilesFailed test for resolveFiles function, files which can't be resolved by errors are reported and run return error
+func TestResolveFilesFailed(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.FlagDelete = true
+
+	files := writeTestFiles(t, "original", "original", "original")
+	// file deleted by other process after scan
+	if err = os.Remove(files[1].Path); err != nil {
+		t.Fatalf("error on remove file: %s", err)
+	}
+
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[1:]}}}
+	err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), nil, context.Background())
+	assert.ErrorIs(t, err, errResolveFailed)
+	if assert.Len(t, fInfo.failedFilesList, 1) {
+		assert.Equal(t, files[1].Path, fInfo.failedFilesList[0].Path)
+	}
+	assert.Len(t, fInfo.resolvedFilesList, 1)
+	assert.NoFileExists(t, files[2].Path)
+}
+
+// TestTrashFile test for resolveFiles function with trash, duplicate move to home trash with .trashinfo file
+func TestTrashFile(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.FlagTrash = true
+
+	files := writeTestFiles(t, "original", "original", "original")
+	dataHome := filepath.Join(filepath.Dir(files[0].Path), "data")
+	t.Setenv("XDG_DATA_HOME", dataHome)
+
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[1:]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), nil, context.Background()); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+
+	assert.Len(t, fInfo.resolvedFilesList, 2)
+	for _, file := range files[1:] {
+		assert.NoFileExists(t, file.Path)
+		assert.FileExists(t, filepath.Join(dataHome, "Trash", "files", file.Name))
+		info, err := ioutil.ReadFile(filepath.Join(dataHome, "Trash", "info", file.Name+".trashinfo"))
+		if err != nil {
+			t.Fatalf("error on read .trashinfo file: %s", err)
+		}
+		assert.Contains(t, string(info), "Path="+file.Path+"\n")
+	}
+
+	// trash directories in source directory don't scan, trashed files can't be originals
+	dir := filepath.Dir(files[0].Path)
+	uid := strconv.Itoa(os.Getuid())
+	for _, trashDir := range []string{filepath.Join(dir, ".Trash-"+uid), filepath.Join(dir, ".Trash", uid)} {
+		if err = makeTrashDir(trashDir); err != nil {
+			t.Fatalf("error on create trash directory: %s", err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(trashDir, "files", files[0].Name), []byte("original"), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+	cfg.App.SourcePath = dir
+	found := filesInfo{}
+	if err = findAllFiles(cfg, &found, context.Background()); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	if assert.Len(t, found.allFilesList, 1) {
+		assert.Equal(t, files[0].Path, found.allFilesList[0].Path)
+	}
+}
+
+// TestUndoJournal test for UndoJournal function, hard links replace by copies, files from trash and quarantine move back,
+// actions without mark of done are skipped
+func TestUndoJournal(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.JournalPath = t.TempDir()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
 
 	ctx := context.Background()
 
-	err = DoDuplicateFiles(cfg, ctx)
+	files := writeTestFiles(t, "original", "original", "original", "original", "original", "original")
+	if err = os.Chmod(files[1].Path, 0600); err != nil {
+		t.Fatalf("error on change permissions of file: %s", err)
+	}
+	cfg.App.SourcePath = filepath.Dir(files[0].Path)
+	quarantinePath := t.TempDir()
+
+	j, err := openJournal(cfg)
+	if err != nil {
+		t.Fatalf("error on create journal: %s", err)
+	}
+	cfg.App.LinkMode = config.LinkHard
+	fInfo := filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[1:2]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), j, ctx); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+	cfg.App.LinkMode = ""
+	cfg.App.FlagTrash = true
+	fInfo = filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[2:3]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), j, ctx); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+	cfg.App.FlagTrash = false
+	cfg.App.QuarantinePath = quarantinePath
+	fInfo = filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[3:4]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), j, ctx); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+	cfg.App.QuarantinePath = ""
+	fInfo = filesInfo{duplicateGroups: []duplicateGroup{{Original: files[0], Duplicates: files[4:5]}}}
+	if err = resolveFiles(cfg, &fInfo, getResolveAction(cfg), j, ctx); err != nil {
+		t.Fatalf("error on resolve files: %s", err)
+	}
+	// action failed after write to journal
+	if err = writeJournal(j, actionSymLink, files[5], files[0], files[0].Path); err != nil {
+		t.Fatalf("error on write journal: %s", err)
+	}
+	if err = j.close(); err != nil {
+		t.Fatalf("error on close journal: %s", err)
+	}
+
+	entries, err := readJournal(j.path())
+	if err != nil {
+		t.Fatalf("error on read journal: %s", err)
+	}
+	assert.Len(t, entries, 9)
+
+	if err = UndoJournal(cfg, j.path(), ctx); err != nil {
+		t.Fatalf("error on undo journal: %s", err)
+	}
+
+	originalInfo, _ := os.Stat(files[0].Path)
+	linkInfo, err := os.Stat(files[1].Path)
+	if err != nil {
+		t.Fatalf("error on get info of file: %s", err)
+	}
+	assert.False(t, os.SameFile(originalInfo, linkInfo), "file %s is hard link to original after undo", files[1].Path)
+	assert.Equal(t, os.FileMode(0600), linkInfo.Mode().Perm())
+	assert.FileExists(t, files[2].Path)
+	assert.FileExists(t, files[3].Path)
+	manifest, err := readQuarantineManifest(quarantinePath)
+	if err != nil {
+		t.Fatalf("error on read manifest of quarantine: %s", err)
+	}
+	assert.Empty(t, manifest)
+	// deleted file is unrecoverable
+	assert.NoFileExists(t, files[4].Path)
+	assert.FileExists(t, files[5].Path)
+
+	// actions already undone can't be undone again
+	assert.Error(t, UndoJournal(cfg, j.path(), ctx))
+}
+
+// TestDryRunApplyPlan test for dry run and ApplyPlan function, dry run don't change files, changed files skipped by apply
+func TestDryRunApplyPlan(t *testing.T) {
+	cfg, err := config.Init()
 	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.FlagDelete = true
+	cfg.App.FlagDryRun = true
+	cfg.App.SourcePath = copyTestFiles(t)
+	cfg.App.PlanPath = filepath.Join(t.TempDir(), "plan.json")
+	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
+	cfg.App.JournalPath = t.TempDir()
+
+	ctx := context.Background()
+
+	if err = DoDuplicateFiles(cfg, ctx); err != nil {
 		t.Fatalf("error on duplicate files function: %s", err)
 	}
 
-	filesA, _ := ioutil.ReadDir(cfg.App.SourcePath)
+	p, err := readPlan(cfg.App.PlanPath)
+	if err != nil {
+		t.Fatalf("error on read plan: %s", err)
+	}
+	assert.Equal(t, actionDelete, p.Action)
+	if !assert.NotEmpty(t, p.Groups) {
+		return
+	}
 
-	assert.NotEqual(t, filesB, filesA, "count of file don't changes, before: %d, after: %d", len(filesB), len(filesA))
+	var duplicates []string
+	var reclaim int64
+	for _, group := range p.Groups {
+		for _, file := range group.Duplicates {
+			duplicates = append(duplicates, file.Path)
+			reclaim += file.Size
+		}
+	}
+	assert.Equal(t, reclaim, p.ReclaimBytes)
+	for _, path := range duplicates {
+		assert.FileExists(t, path, "dry run delete file %s", path)
+	}
+
+	// file on other device with same inode is other file, count of hard links is kept for reclaim
+	file := p.Groups[0].Duplicates[0]
+	assert.Equal(t, uint64(1), file.fileEntity().Links)
+	h := cfg.App.NewHash()
+	if err = file.check(cfg, h, ctx); err != nil {
+		t.Fatalf("error on check file of plan: %s", err)
+	}
+	file.Device++
+	assert.ErrorIs(t, file.check(cfg, h, ctx), errFileChanged)
+
+	// file changed after dry run must stay
+	changed := duplicates[0]
+	if err = ioutil.WriteFile(changed, []byte("changed content"), 0644); err != nil {
+		t.Fatalf("error on change file: %s", err)
+	}
+
+	cfg.App.FlagDelete = false
+	cfg.App.FlagDryRun = false
+	if err = ApplyPlan(cfg, cfg.App.PlanPath, ctx); err != nil {
+		t.Fatalf("error on apply plan: %s", err)
+	}
+
+	assert.FileExists(t, changed)
+	for _, path := range duplicates[1:] {
+		assert.NoFileExists(t, path)
+	}
+}
+
+// TestGetDirReclaimSize test for getDirReclaimSize function, file with hard links count once and only if all links are in directory
+func TestGetDirReclaimSize(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatalf("error on create directory: %s", err)
+	}
+	files := map[string]string{
+		filepath.Join(dir, "a.txt"):  "aaa",
+		filepath.Join(root, "b.txt"): "bbbb",
+		filepath.Join(dir, "c.txt"):  "ccccc",
+	}
+	for path, content := range files {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+	// link to file outside of directory don't reclaim space, links inside directory reclaim space once
+	if err := os.Link(filepath.Join(root, "b.txt"), filepath.Join(dir, "b.txt")); err != nil {
+		t.Skipf("hard links aren't supported: %s", err)
+	}
+	if err := os.Link(filepath.Join(dir, "c.txt"), filepath.Join(dir, "sub", "c.txt")); err != nil {
+		t.Fatalf("error on create hard link: %s", err)
+	}
+
+	size, err := getDirReclaimSize(dir)
+	if err != nil {
+		t.Fatalf("error on get reclaim size of directory: %s", err)
+	}
+	assert.Equal(t, int64(len("aaa")+len("ccccc")), size)
+}
+
+// TestDuplicateDirs test for findDuplicateDirs function and DoDuplicateFiles function with duplicate directories: only top-most
+// directories are reported, files in it aren't reported, directory is deleted by plan of dry run by one action
+func TestDuplicateDirs(t *testing.T) {
+	cfg, err := config.Init()
+	if err != nil {
+		t.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.SourcePath = t.TempDir()
+	root := cfg.App.SourcePath
+	files := map[string]string{
+		"photos/a.jpg":               "aaa",
+		"photos/sub/b.jpg":           "bbb",
+		"backup/photos/a.jpg":        "aaa",
+		"backup/photos/sub/b.jpg":    "bbb",
+		"copy/a.jpg":                 "aaa",
+		"copy/sub/b.jpg":             "bbb",
+		"other/a.jpg":                "aaa",
+		"other/c.txt":                "ccc",
+		"renamed/a.jpg":              "aaa",
+		"renamed/sub/renamed_b.jpg":  "bbb",
+		"withlink/sub/b.jpg":         "bbb",
+		"withlink/sub/unscanned.tmp": "bbb",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("error on create directory: %s", err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("error on create file: %s", err)
+		}
+	}
+	cfg.App.Exclude = []string{"*.tmp"}
+	cfg.App.FlagNoCache = true
+
+	ctx := context.Background()
+	fInfo := filesInfo{directoryList: []string{root}}
+	if err = findAllFiles(cfg, &fInfo, ctx); err != nil {
+		t.Fatalf("error on find all files: %s", err)
+	}
+	candidates := findCandidates(cfg, fInfo.allFilesList, nil, ctx)
+
+	groups := findDuplicateDirs(cfg, fInfo.directoryList, candidates, ctx)
+	if !assert.Len(t, groups, 1) {
+		return
+	}
+	assert.True(t, groups[0].Directory)
+	assert.Equal(t, filepath.Join(root, "photos"), groups[0].Original.Path)
+	var duplicates []string
+	for _, dir := range groups[0].Duplicates {
+		duplicates = append(duplicates, dir.Path)
+	}
+	assert.ElementsMatch(t, []string{filepath.Join(root, "backup", "photos"), filepath.Join(root, "copy")}, duplicates)
+	assert.Equal(t, int64(6), groups[0].Original.Size)
+	treeHash, _, err := hashDirectory(cfg, cfg.App.NewHash(), groups[0].Original.Path, ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, groups[0].Original.Hash, treeHash)
+
+	// files in duplicate directories are resolved with directory
+	remaining := excludeDirFiles(groups, candidates)
+	for _, file := range remaining {
+		assert.False(t, strings.HasPrefix(file.Path, filepath.Join(root, "copy")+string(filepath.Separator)))
+		assert.False(t, strings.HasPrefix(file.Path, filepath.Join(root, "backup")+string(filepath.Separator)))
+	}
+
+	// directory with protected file can't be deleted
+	cfg.App.ProtectedPaths = []string{filepath.Join(root, "copy", "sub", "b.jpg")}
+	groups = findDuplicateDirs(cfg, fInfo.directoryList, candidates, ctx)
+	if assert.Len(t, groups, 1) && assert.Len(t, groups[0].Protected, 1) {
+		assert.Equal(t, filepath.Join(root, "copy"), groups[0].Protected[0].Path)
+	}
+	cfg.App.ProtectedPaths = nil
+
+	cfg.App.FlagDirs = true
+	cfg.App.FlagDelete = true
+	cfg.App.FlagDryRun = true
+	cfg.App.PlanPath = filepath.Join(t.TempDir(), "plan.json")
+	cfg.App.JournalPath = t.TempDir()
+	cfg.App.RunInTest = true
+	if err = DoDuplicateFiles(cfg, ctx); err != nil {
+		t.Fatalf("error on duplicate files function: %s", err)
+	}
+	assert.DirExists(t, filepath.Join(root, "copy"))
+
+	cfg.App.FlagDryRun = false
+	if err = ApplyPlan(cfg, cfg.App.PlanPath, ctx); err != nil {
+		t.Fatalf("error on apply plan: %s", err)
+	}
+	assert.NoDirExists(t, filepath.Join(root, "copy"))
+	assert.NoDirExists(t, filepath.Join(root, "backup", "photos"))
+	assert.FileExists(t, filepath.Join(root, "photos", "sub", "b.jpg"))
+	assert.FileExists(t, filepath.Join(root, "withlink", "sub", "unscanned.tmp"))
+	assert.NoFileExists(t, filepath.Join(root, "other", "a.jpg"))
+	assert.FileExists(t, filepath.Join(root, "other", "c.txt"))
 }
 
 // TestDoRandomCopyFiles test for DoRandomCopyFiles function
@@ -44,8 +1471,9 @@ func TestDoRandomCopyFiles(t *testing.T) {
 
 	cfg.App.FlagRandCopy = true
 	cfg.App.FlagDelete = false
-	cfg.App.SourcePath = "../TestFiles"
+	cfg.App.SourcePath = copyTestFiles(t)
 	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
 
 	filesB, _ := ioutil.ReadDir(cfg.App.SourcePath)
 
@@ -69,8 +1497,9 @@ func BenchmarkDoDuplicateFiles_1go(b *testing.B) {
 	}
 
 	cfg.App.FlagDelete = false
-	cfg.App.SourcePath = "../TestFiles"
+	cfg.App.SourcePath = copyTestFiles(b)
 	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
 	cfg.App.CountGoroutine = 1
 
 	ctx := context.Background()
@@ -91,8 +1520,9 @@ func BenchmarkDoRandomCopyFiles_1go(b *testing.B) {
 
 	cfg.App.FlagRandCopy = true
 	cfg.App.FlagDelete = false
-	cfg.App.SourcePath = "../TestFiles"
+	cfg.App.SourcePath = copyTestFiles(b)
 	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
 	cfg.App.CountGoroutine = 1
 	cfg.App.CountRndCopyIter = 1000
 
@@ -113,8 +1543,9 @@ func BenchmarkDoDuplicateFiles_1000go(b *testing.B) {
 	}
 
 	cfg.App.FlagDelete = false
-	cfg.App.SourcePath = "../TestFiles"
+	cfg.App.SourcePath = copyTestFiles(b)
 	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
 	cfg.App.CountGoroutine = 1000
 
 	ctx := context.Background()
@@ -135,8 +1566,9 @@ func BenchmarkDoRandomCopyFiles_1000go(b *testing.B) {
 
 	cfg.App.FlagRandCopy = true
 	cfg.App.FlagDelete = false
-	cfg.App.SourcePath = "../TestFiles"
+	cfg.App.SourcePath = copyTestFiles(b)
 	cfg.App.RunInTest = true
+	cfg.App.FlagNoCache = true
 	cfg.App.CountGoroutine = 1000
 	cfg.App.CountRndCopyIter = 1000
 
@@ -149,6 +1581,67 @@ func BenchmarkDoRandomCopyFiles_1000go(b *testing.B) {
 	}
 }
 
+// createBenchFiles helper create tree of directories with files for benchmarks, every second file is a copy, return path to tree
+func createBenchFiles(b *testing.B, countDirs int, countFiles int) string {
+	b.Helper()
+
+	root := b.TempDir()
+	content := make([]byte, 64*1024)
+	for d := 0; d < countDirs; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%d", d), "sub")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatalf("error on create directory: %s", err)
+		}
+		for f := 0; f < countFiles; f++ {
+			// same size of all files, duplicates find only by hash
+			copy(content, fmt.Sprintf("%d-%d", d*countFiles/2, f/2))
+			if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.bin", f)), content, 0644); err != nil {
+				b.Fatalf("error on write file: %s", err)
+			}
+		}
+	}
+
+	return root
+}
+
+// benchmarkDoDuplicateFilesTree helper bench DoDuplicateFiles function on big tree of files with count of goroutines
+func benchmarkDoDuplicateFilesTree(b *testing.B, countGoroutine int) {
+	cfg, err := config.Init()
+	if err != nil {
+		b.Fatalf("error on load configuration file: %s", err)
+	}
+
+	cfg.App.Logger = zap.NewNop()
+	cfg.App.FlagDelete = false
+	cfg.App.SourcePath = createBenchFiles(b, 50, 40)
+	cfg.App.FlagNoCache = true
+	cfg.App.CountGoroutine = countGoroutine
+
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := DoDuplicateFiles(cfg, ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDoDuplicateFilesTree_1go bench for DoDuplicateFiles function on big tree, use 1 goroutine
+func BenchmarkDoDuplicateFilesTree_1go(b *testing.B) {
+	benchmarkDoDuplicateFilesTree(b, 1)
+}
+
+// BenchmarkDoDuplicateFilesTree_4go bench for DoDuplicateFiles function on big tree, use 4 goroutines
+func BenchmarkDoDuplicateFilesTree_4go(b *testing.B) {
+	benchmarkDoDuplicateFilesTree(b, 4)
+}
+
+// BenchmarkDoDuplicateFilesTree_16go bench for DoDuplicateFiles function on big tree, use 16 goroutines
+func BenchmarkDoDuplicateFilesTree_16go(b *testing.B) {
+	benchmarkDoDuplicateFilesTree(b, 16)
+}
+
 // ExampleDoDuplicateFiles example for use DoDuplicateFiles function
 func ExampleDoDuplicateFiles() {
 	// set source directory in config.yaml file or use flags (-h for help)
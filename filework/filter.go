@@ -0,0 +1,217 @@
+package filework
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/White-AK111/fileworker/ignore"
+	"go.uber.org/zap"
+)
+
+// scanFilter struct for filter directories and files on walk by include and exclude patterns,
+// excluded directories don't walk, include patterns and filters of size, modification time and type filter only files
+type scanFilter struct {
+	root           string          // source directory, patterns match path relative to it
+	include        *ignore.Matcher // patterns of files for scan
+	exclude        *ignore.Matcher // patterns of directories and files for skip
+	minSize        int64           // min size of file
+	maxSize        int64           // max size of file, 0 - without limit
+	modifiedBefore time.Time       // files modified before date, zero - without limit
+	modifiedAfter  time.Time       // files modified after date, zero - without limit
+	extensions     map[string]bool // extensions of files in lower case with dot
+	mimeTypes      []string        // patterns of MIME types
+}
+
+// newScanFilter function compile include and exclude patterns from config for source directory, return *scanFilter and error
+func newScanFilter(cfg *config.Config, root string) (*scanFilter, error) {
+	include, err := ignore.NewMatcher(cfg.App.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := ignore.NewMatcher(cfg.App.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiedBefore, err := cfg.GetModifiedBefore()
+	if err != nil {
+		return nil, err
+	}
+	modifiedAfter, err := cfg.GetModifiedAfter()
+	if err != nil {
+		return nil, err
+	}
+
+	var extensions map[string]bool
+	if len(cfg.App.Extensions) > 0 {
+		extensions = make(map[string]bool)
+		for _, ext := range cfg.App.Extensions {
+			extensions["."+strings.TrimPrefix(strings.ToLower(ext), ".")] = true
+		}
+	}
+
+	return &scanFilter{
+		root:           root,
+		include:        include,
+		exclude:        exclude,
+		minSize:        cfg.GetMinSize(),
+		maxSize:        cfg.App.MaxSize,
+		modifiedBefore: modifiedBefore,
+		modifiedAfter:  modifiedAfter,
+		extensions:     extensions,
+		mimeTypes:      cfg.App.MimeTypes,
+	}, nil
+}
+
+// relPath method return path relative to source directory with "/" as separator, return string
+func (f *scanFilter) relPath(path string) string {
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// skipDir method check directory is excluded, return bool
+func (f *scanFilter) skipDir(path string) bool {
+	excluded, _ := f.exclude.Match(f.relPath(path), true)
+
+	return excluded
+}
+
+// skipFile method check file is excluded, isn't included or don't pass filters of size, modification time, extension and MIME type,
+// return bool and reason of skip
+func (f *scanFilter) skipFile(path string, info os.FileInfo) (bool, string) {
+	rel := f.relPath(path)
+	if excluded, _ := f.exclude.Match(rel, false); excluded {
+		return true, "excluded"
+	}
+	if f.include.Len() > 0 {
+		if included, _ := f.include.Match(rel, false); !included {
+			return true, "not included"
+		}
+	}
+
+	if info.Size() < f.minSize || (f.maxSize > 0 && info.Size() > f.maxSize) {
+		return true, "size out of range"
+	}
+	if (!f.modifiedBefore.IsZero() && !info.ModTime().Before(f.modifiedBefore)) ||
+		(!f.modifiedAfter.IsZero() && !info.ModTime().After(f.modifiedAfter)) {
+		return true, "modification time out of range"
+	}
+	if f.extensions != nil && !f.extensions[strings.ToLower(filepath.Ext(path))] {
+		return true, "extension not matched"
+	}
+	if len(f.mimeTypes) > 0 {
+		mimeType, err := detectMimeType(path)
+		if err != nil {
+			return true, err.Error()
+		}
+		if !matchMimeType(f.mimeTypes, mimeType) {
+			return true, "MIME type " + mimeType + " not matched"
+		}
+	}
+
+	return false, ""
+}
+
+// detectMimeType function detect MIME type of file by first bytes of content, return string and error
+func detectMimeType(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// content detection use at most 512 bytes
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+
+	mimeType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+
+	return mimeType, err
+}
+
+// matchMimeType function check MIME type match one of patterns, return bool
+func matchMimeType(patterns []string, mimeType string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := pathpkg.Match(pattern, mimeType); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// names of ignore files in order of priority, rules of next file override rules of previous
+var ignoreFileNames = []string{".gitignore", ".ignore", ".fileworkerignore"}
+
+// ignoreRules struct for rules of ignore files of one directory, rules of parent directories linked like in git
+type ignoreRules struct {
+	dir     string          // directory of ignore files, rules match path relative to it
+	matcher *ignore.Matcher // rules of all ignore files in directory
+	parent  *ignoreRules    // rules of parent directories
+}
+
+// readIgnoreRules function read ignore files in directory, wrong files are skipped, directory without rules return rules of parent,
+// return *ignoreRules
+func readIgnoreRules(cfg *config.Config, dir string, parent *ignoreRules) *ignoreRules {
+	var matchers []*ignore.Matcher
+	for _, name := range ignoreFileNames {
+		path := filepath.Join(dir, name)
+		file, err := os.Open(path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				cfg.App.Logger.Warn("Error on open ignore file, file skipped.",
+					zap.String("file", path),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+		matcher, err := ignore.ReadMatcher(file)
+		_ = file.Close()
+		if err != nil {
+			cfg.App.Logger.Warn("Wrong rules in ignore file, file skipped.",
+				zap.String("file", path),
+				zap.Error(err),
+			)
+			continue
+		}
+		matchers = append(matchers, matcher)
+	}
+	if len(matchers) == 0 {
+		return parent
+	}
+
+	matcher := ignore.Merge(matchers...)
+	cfg.App.Logger.With(zap.String("directory", dir), zap.Int("rules", matcher.Len())).Debug("Read ignore files.")
+
+	return &ignoreRules{dir: dir, matcher: matcher, parent: parent}
+}
+
+// ignored method check path by rules from deepest directory to source directory, first directory with matched rule decide, return bool
+func (r *ignoreRules) ignored(path string, isDir bool) bool {
+	for rules := r; rules != nil; rules = rules.parent {
+		rel, err := filepath.Rel(rules.dir, path)
+		if err != nil {
+			continue
+		}
+		if ignored, matched := rules.matcher.Match(filepath.ToSlash(rel), isDir); matched {
+			return ignored
+		}
+	}
+
+	return false
+}
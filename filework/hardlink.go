@@ -0,0 +1,98 @@
+package filework
+
+import "sort"
+
+// groupLinks function group paths of one file (hard links with same device and inode), only first path by name of every file
+// compare with other files, files without inode (file system don't give it) aren't grouped, return []FileEntity with one path
+// of every file and [][]FileEntity with paths of files linked by hard links
+func groupLinks(files []FileEntity) ([]FileEntity, [][]FileEntity) {
+	var keys []fileKey
+	links := make(map[fileKey][]FileEntity)
+	unique := make([]FileEntity, 0, len(files))
+	for _, file := range files {
+		if file.Inode == 0 {
+			unique = append(unique, file)
+			continue
+		}
+		key := fileKey{dev: file.Device, ino: file.Inode}
+		if _, ok := links[key]; !ok {
+			keys = append(keys, key)
+		}
+		links[key] = append(links[key], file)
+	}
+
+	var linked [][]FileEntity
+	for _, key := range keys {
+		group := links[key]
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Path < group[j].Path
+		})
+		unique = append(unique, group[0])
+		if len(group) > 1 {
+			linked = append(linked, group)
+		}
+	}
+
+	sort.Slice(linked, func(i, j int) bool {
+		return linked[i][0].Path < linked[j][0].Path
+	})
+
+	return unique, linked
+}
+
+// expandLinks function add other paths of duplicate files linked by hard links to groups of duplicates, all paths of file resolve
+// together, when scan found all links of file last path reclaim space of file. Paths of originals and unique files stay
+// already linked, paths in reference directories are never resolved and keep content of file,
+// return []duplicateGroup and [][]FileEntity with remaining groups of linked paths
+func expandLinks(protected []string, references []string, groups []duplicateGroup, linked [][]FileEntity) ([]duplicateGroup, [][]FileEntity) {
+	byPath := make(map[string][]FileEntity, len(linked))
+	for _, group := range linked {
+		byPath[group[0].Path] = group
+	}
+
+	expanded := make(map[string]bool)
+	for i := range groups {
+		var duplicates []FileEntity
+		for _, file := range groups[i].Duplicates {
+			paths, ok := byPath[file.Path]
+			if !ok {
+				duplicates = append(duplicates, file)
+				continue
+			}
+			expanded[file.Path] = true
+
+			var targets []FileEntity
+			for _, path := range paths {
+				if !isReference(references, path.Path) {
+					targets = append(targets, path)
+				}
+			}
+			resolved, protectedPaths := splitProtected(protected, targets)
+			// protected path, path in reference directory or path outside of scan keep content of file
+			if len(resolved) > 0 && len(protectedPaths) == 0 && uint64(len(resolved)) == file.Links {
+				resolved[len(resolved)-1].Links = 1
+			}
+			duplicates = append(duplicates, resolved...)
+			groups[i].Protected = append(groups[i].Protected, protectedPaths...)
+		}
+		groups[i].Duplicates = duplicates
+	}
+
+	var rest [][]FileEntity
+	for _, group := range linked {
+		if !expanded[group[0].Path] {
+			rest = append(rest, group)
+		}
+	}
+
+	return groups, rest
+}
+
+// getReclaimSize function return count of bytes which free on remove of file, file with other hard links don't free space, return int64
+func getReclaimSize(file FileEntity) int64 {
+	if file.Links > 1 {
+		return 0
+	}
+
+	return file.Size
+}
@@ -0,0 +1,314 @@
+package filework
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// names of actions with duplicate files, it's save in journal
+const (
+	actionDelete     = "delete"
+	actionDeleteDir  = "delete directory"
+	actionHardLink   = "hard link"
+	actionSymLink    = "symbolic link"
+	actionReflink    = "reflink"
+	actionQuarantine = "quarantine"
+	actionTrash      = "trash"
+)
+
+// journal struct for write undo journal of destructive run, every action write to journal before it performed
+// and mark as done after success
+type journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// journalEntry struct for save one action with duplicate file in journal
+type journalEntry struct {
+	Action     string      `json:"action"`               // name of action
+	Path       string      `json:"path"`                 // path of duplicate file
+	Original   string      `json:"original"`             // path of original file
+	Target     string      `json:"target,omitempty"`     // new path of file or target of link
+	Hash       string      `json:"hash"`                 // hash of file
+	Size       int64       `json:"size"`                 // size of file
+	Mode       os.FileMode `json:"mode"`                 // permissions and mode of file before action
+	Quarantine string      `json:"quarantine,omitempty"` // quarantine directory with manifest of moved file
+	Done       bool        `json:"done,omitempty"`       // entry mark action as completed, it write after action
+	Time       time.Time   `json:"time"`                 // time of action
+}
+
+// newJournalEntry function initialize new entry of journal for action with duplicate file, return journalEntry and error
+func newJournalEntry(action string, file FileEntity, original FileEntity, target string) (journalEntry, error) {
+	info, err := os.Lstat(file.Path)
+	if err != nil {
+		return journalEntry{}, err
+	}
+
+	return journalEntry{
+		Action:   action,
+		Path:     file.Path,
+		Original: original.Path,
+		Target:   target,
+		Hash:     file.Hash,
+		Size:     file.Size,
+		Mode:     info.Mode(),
+		Time:     time.Now(),
+	}, nil
+}
+
+// openJournal function create new journal file in journal directory, return *journal and error
+func openJournal(cfg *config.Config) (*journal, error) {
+	dir, err := cfg.GetJournalPath()
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("journal-%s.jsonl", time.Now().Format("20060102-150405.000000000")))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{file: file}, nil
+}
+
+// path method return path of journal file, nil journal return empty string
+func (j *journal) path() string {
+	if j == nil {
+		return ""
+	}
+
+	return j.file.Name()
+}
+
+// write method append entry to journal and sync it to disk before action, nil journal do nothing, return error
+func (j *journal) write(entry journalEntry) error {
+	if j == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err = j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	return j.file.Sync()
+}
+
+// done method append mark of completed action to journal, only completed actions can be undone, nil journal do nothing, return error
+func (j *journal) done(action string, path string) error {
+	return j.write(journalEntry{Action: action, Path: path, Done: true, Time: time.Now()})
+}
+
+// close method close journal file, nil journal do nothing, return error
+func (j *journal) close() error {
+	if j == nil {
+		return nil
+	}
+
+	return j.file.Close()
+}
+
+// readJournal function read all entries of journal file, return []journalEntry and error
+func readJournal(path string) ([]journalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// UndoJournal function reverse completed actions from journal in reverse order: files move back from quarantine and trash,
+// links replace by copies of original, deleted files and directories report as unrecoverable, actions without mark of done
+// are skipped, return error if any action can't be undone
+func UndoJournal(cfg *config.Config, path string, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "UndoJournal")
+	defer span.Finish()
+
+	entries, err := readJournal(path)
+	if err != nil {
+		cfg.App.Logger.Error("Error on read journal.",
+			zap.String("journal", path),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// action failed or skipped after write to journal don't have mark of done
+	completed := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Done {
+			completed[entry.Action+"\x00"+entry.Path] = true
+		}
+	}
+
+	countUndo, countFailed, countNotDone := 0, 0, 0
+	var unrecoverable []journalEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Done {
+			continue
+		}
+		if !completed[entry.Action+"\x00"+entry.Path] {
+			cfg.App.Logger.Debug("Action wasn't completed, entry skipped.",
+				zap.String("action", entry.Action),
+				zap.String("file", entry.Path),
+			)
+			countNotDone++
+			continue
+		}
+		if entry.Action == actionDelete || entry.Action == actionDeleteDir {
+			unrecoverable = append(unrecoverable, entry)
+			continue
+		}
+
+		if err := undoEntry(cfg, entry, ctx); err != nil {
+			cfg.App.Logger.Error("Error on undo action.",
+				zap.String("action", entry.Action),
+				zap.String("file", entry.Path),
+				zap.Error(err),
+			)
+			countFailed++
+			continue
+		}
+		fmt.Printf("Undo %s: %s\n", entry.Action, entry.Path)
+		countUndo++
+	}
+
+	for _, entry := range unrecoverable {
+		fmt.Printf("Unrecoverable deleted file: %s	Original file: %s\n", entry.Path, entry.Original)
+	}
+	fmt.Printf("Undone actions: %d\n", countUndo)
+	fmt.Printf("Failed actions: %d\n", countFailed)
+	fmt.Printf("Not completed actions: %d\n", countNotDone)
+	fmt.Printf("Unrecoverable deleted files: %d\n", len(unrecoverable))
+
+	if countFailed > 0 {
+		return fmt.Errorf("can't undo %d actions from journal %s", countFailed, path)
+	}
+
+	return nil
+}
+
+// undoEntry function reverse one action from journal, return error
+func undoEntry(cfg *config.Config, entry journalEntry, ctx context.Context) error {
+	switch entry.Action {
+	case actionQuarantine:
+		if _, err := os.Lstat(entry.Path); err == nil {
+			return fmt.Errorf("file already exist: %s", entry.Path)
+		}
+		if err := moveFile(cfg, entry.Target, entry.Path, ctx); err != nil {
+			return err
+		}
+		// manifest of quarantine directory must not list restored file
+		if entry.Quarantine == "" {
+			return nil
+		}
+		return removeQuarantineEntry(entry.Quarantine, entry.Target)
+	case actionTrash:
+		if _, err := os.Lstat(entry.Path); err == nil {
+			return fmt.Errorf("file already exist: %s", entry.Path)
+		}
+		if err := moveFile(cfg, entry.Target, entry.Path, ctx); err != nil {
+			return err
+		}
+		// trash directory contains files and info subdirectories
+		info := filepath.Join(filepath.Dir(filepath.Dir(entry.Target)), "info", filepath.Base(entry.Target)+".trashinfo")
+		return os.Remove(info)
+	case actionHardLink, actionSymLink:
+		return unlinkFile(cfg, entry, ctx)
+	case actionReflink:
+		// files with shared extents stay independent, nothing to undo
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q", entry.Action)
+	}
+}
+
+// unlinkFile function replace link by copy of original file with permissions of duplicate, link changed after run don't touch, return error
+func unlinkFile(cfg *config.Config, entry journalEntry, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "unlinkFile")
+	defer span.Finish()
+
+	linkInfo, err := os.Lstat(entry.Path)
+	if err != nil {
+		return err
+	}
+	switch entry.Action {
+	case actionSymLink:
+		if linkInfo.Mode()&os.ModeSymlink == 0 {
+			return fmt.Errorf("file isn't symbolic link: %s", entry.Path)
+		}
+	case actionHardLink:
+		originalInfo, err := os.Lstat(entry.Original)
+		if err != nil {
+			return err
+		}
+		if !os.SameFile(linkInfo, originalInfo) {
+			return fmt.Errorf("file isn't hard link to original: %s", entry.Path)
+		}
+	}
+
+	source, err := os.Open(entry.Original)
+	if err != nil {
+		return err
+	}
+	defer fileClose(cfg, source, ctx)
+
+	tmpPath := getTempPath(entry.Path)
+	destination, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, entry.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	if err = byteCopy(cfg, source, destination, ctx); err != nil {
+		fileClose(cfg, destination, ctx)
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err = destination.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	// permissions of new file depend on umask
+	if err = os.Chmod(tmpPath, entry.Mode.Perm()); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, entry.Path)
+}
@@ -0,0 +1,132 @@
+package filework
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/White-AK111/fileworker/config"
+)
+
+// compareFunc function type for compare two files by policy of select original file,
+// return negative number if file a is better original, positive if file b is better and zero if files are equal by policy
+type compareFunc func(a FileEntity, b FileEntity) int
+
+// getOriginalCompares function return functions for compare files by policies from config in order of priority,
+// protected files always win, without policies file from first of several source directories win, return []compareFunc
+func getOriginalCompares(cfg *config.Config) []compareFunc {
+	var compares []compareFunc
+	// protected file is original before all policies, it can't be resolved anyway
+	if len(cfg.App.ProtectedPaths) > 0 {
+		protected := cfg.App.ProtectedPaths
+		compares = append(compares, func(a FileEntity, b FileEntity) int {
+			return boolToInt(isProtected(protected, b.Path)) - boolToInt(isProtected(protected, a.Path))
+		})
+	}
+	policies := cfg.App.OriginalPolicy
+	if len(policies) == 0 && len(cfg.GetSourcePaths()) > 1 {
+		policies = []string{config.OriginalRoot}
+	}
+	for _, policy := range policies {
+		switch policy {
+		case config.OriginalOldest:
+			compares = append(compares, compareOldest)
+		case config.OriginalNewest:
+			compares = append(compares, func(a FileEntity, b FileEntity) int {
+				return compareOldest(b, a)
+			})
+		case config.OriginalShortestPath:
+			compares = append(compares, func(a FileEntity, b FileEntity) int {
+				return len(a.Path) - len(b.Path)
+			})
+		case config.OriginalShallowest:
+			compares = append(compares, func(a FileEntity, b FileEntity) int {
+				return getPathDepth(a.Path) - getPathDepth(b.Path)
+			})
+		case config.OriginalPreferred:
+			preferred := cfg.App.PreferredPaths
+			compares = append(compares, func(a FileEntity, b FileEntity) int {
+				return getPreferredIndex(preferred, a.Path) - getPreferredIndex(preferred, b.Path)
+			})
+		case config.OriginalRoot:
+			sourcePaths := cfg.GetSourcePaths()
+			compares = append(compares, func(a FileEntity, b FileEntity) int {
+				return getPreferredIndex(sourcePaths, a.Path) - getPreferredIndex(sourcePaths, b.Path)
+			})
+		case config.OriginalLongestName:
+			compares = append(compares, func(a FileEntity, b FileEntity) int {
+				return len(b.Name) - len(a.Name)
+			})
+		}
+	}
+
+	return compares
+}
+
+// selectOriginal function select original file in group of duplicate files by policies, ties of all policies break by last file by path,
+// duplicates sort by path, return duplicateGroup
+func selectOriginal(group []FileEntity, compares []compareFunc) duplicateGroup {
+	sort.Slice(group, func(i, j int) bool {
+		return group[i].Path < group[j].Path
+	})
+
+	best := len(group) - 1
+	for i := len(group) - 2; i >= 0; i-- {
+		for _, compare := range compares {
+			if c := compare(group[i], group[best]); c != 0 {
+				if c < 0 {
+					best = i
+				}
+				break
+			}
+		}
+	}
+
+	duplicates := make([]FileEntity, 0, len(group)-1)
+	duplicates = append(duplicates, group[:best]...)
+	duplicates = append(duplicates, group[best+1:]...)
+
+	return duplicateGroup{
+		Original:   group[best],
+		Duplicates: duplicates,
+	}
+}
+
+// compareOldest function compare files by modification time, older file is better, return int
+func compareOldest(a FileEntity, b FileEntity) int {
+	switch {
+	case a.Create.Before(b.Create):
+		return -1
+	case a.Create.After(b.Create):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// getPathDepth function return count of directories in path, return int
+func getPathDepth(path string) int {
+	return strings.Count(filepath.Clean(path), string(filepath.Separator))
+}
+
+// getPreferredIndex function return index of first preferred (or source) directory which contains path, path outside of all directories
+// get count of directories, return int
+func getPreferredIndex(preferred []string, path string) int {
+	for i, dir := range preferred {
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return i
+		}
+	}
+
+	return len(preferred)
+}
+
+// boolToInt function convert bool to int, return 1 for true and 0 for false
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,251 @@
+package filework
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/White-AK111/fileworker/hasher"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// findCandidates function narrow list of files to candidates for duplicates: files group by size, then by partial hash,
+// full hash get only for files which still collide, hashes of unchanged files load from cache, return []FileEntity with full hash
+func findCandidates(cfg *config.Config, files []FileEntity, cache *hashCache, ctx context.Context) []FileEntity {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "findCandidates")
+	defer span.Finish()
+
+	// first stage, files with unique size can't have duplicates
+	sizeGroups := make(map[int64][]FileEntity)
+	for _, file := range files {
+		sizeGroups[file.Size] = append(sizeGroups[file.Size], file)
+	}
+
+	// second stage, get partial hash for files with same size
+	var sameSize []FileEntity
+	for _, group := range sizeGroups {
+		if len(group) < 2 {
+			continue
+		}
+		for _, file := range group {
+			if cache.load(&file) {
+				cfg.App.Logger.With(zap.String("file", file.Path)).Debug("Load file hash from cache.")
+			}
+			sameSize = append(sameSize, file)
+		}
+	}
+	sameSize = hashFiles(cfg, sameSize, true, ctx)
+
+	partialGroups := make(map[string][]FileEntity)
+	for _, file := range sameSize {
+		key := fmt.Sprintf("%d:%s", file.Size, file.PartialHash)
+		partialGroups[key] = append(partialGroups[key], file)
+	}
+
+	// third stage, get full hash for files with same size and partial hash
+	var samePartial []FileEntity
+	for _, group := range partialGroups {
+		if len(group) > 1 {
+			samePartial = append(samePartial, group...)
+		}
+	}
+	candidates := hashFiles(cfg, samePartial, false, ctx)
+
+	// save hashes for next scans, files without candidates save with partial hash
+	if err := cache.store(sameSize); err != nil {
+		cfg.App.Logger.Warn("Can't save hashes of files to cache",
+			zap.Error(err),
+		)
+	}
+	if err := cache.store(candidates); err != nil {
+		cfg.App.Logger.Warn("Can't save hashes of files to cache",
+			zap.Error(err),
+		)
+	}
+
+	cfg.App.Logger.Debug("Find candidates for duplicates.",
+		zap.Int("files", len(files)),
+		zap.Int("size groups", len(sizeGroups)),
+		zap.Int("partial hash groups", len(partialGroups)),
+		zap.Int("candidates", len(candidates)),
+	)
+
+	return candidates
+}
+
+// hashFiles function get partial or full hash of files without it by pool of workers, every worker use own hash,
+// files with error of hashing are dropped, return []FileEntity with hashes
+func hashFiles(cfg *config.Config, files []FileEntity, partial bool, ctx context.Context) []FileEntity {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "hashFiles")
+	defer span.Finish()
+
+	countWorkers := cfg.App.CountGoroutine
+	if countWorkers > len(files) {
+		countWorkers = len(files)
+	}
+
+	// every worker write only to own items of slices
+	failed := make([]bool, len(files))
+	jobs := make(chan int, countWorkers)
+	wg := sync.WaitGroup{}
+	for w := 0; w < countWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer catchRecover(cfg, ctx)
+			defer wg.Done()
+
+			h := cfg.App.NewHash()
+			for i := range jobs {
+				file := &files[i]
+				var err error
+				switch {
+				case partial && file.PartialHash == "":
+					err = file.getPartialHashOfFile(cfg, h, ctx)
+				case !partial && file.Hash == "":
+					err = file.getHashOfFile(cfg, h, ctx)
+				}
+				if err != nil {
+					cfg.App.Logger.Error("Can't get hash of file",
+						zap.String("file", file.Path),
+						zap.Bool("partial", partial),
+						zap.Error(err),
+					)
+					failed[i] = true
+				}
+			}
+		}()
+	}
+
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	result := make([]FileEntity, 0, len(files))
+	for i, file := range files {
+		if !failed[i] {
+			result = append(result, file)
+		}
+	}
+
+	return result
+}
+
+// groupDuplicates function group files with full hash by size and hash, files in root directory priority are considered like original,
+// groups found by non-cryptographic hash verify byte by byte, with reference directories only groups with files of reference
+// and of source directories are returned, return []duplicateGroup
+func groupDuplicates(cfg *config.Config, files []FileEntity, ctx context.Context) []duplicateGroup {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "groupDuplicates")
+	defer span.Finish()
+
+	hashGroups := make(map[string][]FileEntity)
+	for _, file := range files {
+		key := fmt.Sprintf("%d:%s", file.Size, file.Hash)
+		hashGroups[key] = append(hashGroups[key], file)
+	}
+
+	var clusters [][]FileEntity
+	for _, group := range hashGroups {
+		if len(group) > 1 {
+			clusters = append(clusters, group)
+		}
+	}
+
+	// fast hash can give collisions, verify content of files
+	if needVerifyHash(cfg) {
+		cfg.App.Logger.Debug("Verify files byte by byte.", zap.String("algorithm", cfg.App.HashAlgorithmName))
+		clusters = splitByContent(cfg, clusters, ctx)
+	}
+
+	// original select by policies, without policies last file by path is original
+	compares := getOriginalCompares(cfg)
+	var groups []duplicateGroup
+	for _, group := range clusters {
+		var dg duplicateGroup
+		// in reference mode original is file of reference, copies inside reference or only in source directories are ignored
+		if len(cfg.App.ReferencePaths) > 0 {
+			var ok bool
+			if dg, ok = selectReferenceOriginal(cfg.App.ReferencePaths, group, compares); !ok {
+				continue
+			}
+		} else {
+			dg = selectOriginal(group, compares)
+		}
+		// protected copies stay untouched, group with all protected files only report
+		dg.Duplicates, dg.Protected = splitProtected(cfg.App.ProtectedPaths, dg.Duplicates)
+		groups = append(groups, dg)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Original.Path < groups[j].Original.Path
+	})
+
+	return groups
+}
+
+// needVerifyHash function check hash algorithm is non-cryptographic and verify isn't disabled, return bool
+func needVerifyHash(cfg *config.Config) bool {
+	if cfg.App.FlagNoVerifyHash {
+		return false
+	}
+
+	algorithm, err := hasher.Get(cfg.App.HashAlgorithmName)
+	if err != nil {
+		return true
+	}
+
+	return !algorithm.Cryptographic
+}
+
+// splitByContent function split groups of files with same hash to groups of files with same content, return [][]FileEntity
+func splitByContent(cfg *config.Config, clusters [][]FileEntity, ctx context.Context) [][]FileEntity {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "splitByContent")
+	defer span.Finish()
+
+	var result [][]FileEntity
+	for _, cluster := range clusters {
+		var parts [][]FileEntity
+		for _, file := range cluster {
+			found := false
+			for i, part := range parts {
+				equal, err := compareFiles(cfg, part[0].Path, file.Path, ctx)
+				if err != nil {
+					cfg.App.Logger.Error("Can't compare files",
+						zap.String("file", file.Path),
+						zap.String("another file", part[0].Path),
+						zap.Error(err),
+					)
+					found = true
+					break
+				}
+				if equal {
+					parts[i] = append(parts[i], file)
+					found = true
+					break
+				}
+			}
+			if !found {
+				parts = append(parts, []FileEntity{file})
+			}
+		}
+
+		if len(parts) > 1 {
+			cfg.App.Logger.Warn("Hash collision, files with same hash have different content.",
+				zap.String("hash", cluster[0].Hash),
+				zap.Int("files", len(cluster)),
+				zap.Int("different contents", len(parts)),
+			)
+		}
+		for _, part := range parts {
+			if len(part) > 1 {
+				result = append(result, part)
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,361 @@
+package filework
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/White-AK111/fileworker/hasher"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// planVersion version of format of plan file, version 2 save device and count of hard links of files
+const planVersion = 2
+
+// errFileChanged error for files which changed after plan was made
+var errFileChanged = errors.New("file changed since plan")
+
+// plan struct for save all actions with duplicate files of dry run, plan can be applied later
+type plan struct {
+	Version        int         `json:"version"`                  // version of format
+	Created        time.Time   `json:"created"`                  // time of dry run
+	Action         string      `json:"action"`                   // name of action with duplicate files
+	SourcePath     string      `json:"sourcePath"`               // source directory of dry run
+	SourcePaths    []string    `json:"sourcePaths,omitempty"`    // source directories of dry run with several source directories
+	ReferencePaths []string    `json:"referencePaths,omitempty"` // read-only reference directories of dry run
+	HashAlgorithm  string      `json:"hashAlgorithm"`            // name of hash algorithm of files hashes
+	QuarantinePath string      `json:"quarantinePath,omitempty"` // quarantine directory for move duplicate files
+	LinkTarget     string      `json:"linkTarget,omitempty"`     // target of symbolic links
+	PreserveAttrs  bool        `json:"preserveAttrs,omitempty"`  // flag for preserve permissions and owner of duplicate files
+	Verify         bool        `json:"verify,omitempty"`         // flag for compare duplicate with original byte by byte
+	ReclaimBytes   int64       `json:"reclaimBytes"`             // count of bytes which will be reclaimed
+	Groups         []planGroup `json:"groups"`                   // groups of duplicate files
+}
+
+// planGroup struct for save one original file and it's copies in plan
+type planGroup struct {
+	Original   planFile   `json:"original"`            // original file
+	Duplicates []planFile `json:"duplicates"`          // copies of original file
+	Directory  bool       `json:"directory,omitempty"` // group of duplicate directories
+}
+
+// planFile struct for save state of file in plan, before apply state compare with file on disk
+type planFile struct {
+	Path    string    `json:"path"`   // path to file with name of file
+	Size    int64     `json:"size"`   // size of file
+	ModTime time.Time `json:"mtime"`  // modification time of file
+	Device  uint64    `json:"device"` // device of file
+	Inode   uint64    `json:"inode"`  // inode of file
+	Links   uint64    `json:"links"`  // count of hard links of file
+	Hash    string    `json:"hash"`   // hash of file
+}
+
+// newPlanFile function initialize new planFile from FileEntity, return planFile
+func newPlanFile(f FileEntity) planFile {
+	return planFile{
+		Path:    f.Path,
+		Size:    f.Size,
+		ModTime: f.Create,
+		Device:  f.Device,
+		Inode:   f.Inode,
+		Links:   f.Links,
+		Hash:    f.Hash,
+	}
+}
+
+// fileEntity method convert planFile to FileEntity, return FileEntity
+func (f planFile) fileEntity() FileEntity {
+	return FileEntity{
+		Create: f.ModTime,
+		Name:   filepath.Base(f.Path),
+		Path:   f.Path,
+		Hash:   f.Hash,
+		Size:   f.Size,
+		Device: f.Device,
+		Inode:  f.Inode,
+		Links:  f.Links,
+	}
+}
+
+// check method compare file on disk with state of file in plan by size, modification time, device and inode and hash, return error
+func (f planFile) check(cfg *config.Config, h hash.Hash, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "planFile.check")
+	defer span.Finish()
+
+	info, err := os.Lstat(f.Path)
+	if err != nil {
+		return err
+	}
+	// directory compare by tree hash of all files in it
+	if info.IsDir() {
+		treeHash, _, err := hashDirectory(cfg, h, f.Path, ctx)
+		if err != nil {
+			return err
+		}
+		if treeHash != f.Hash {
+			return fmt.Errorf("%w: tree hash of directory differ", errFileChanged)
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: file isn't regular file", errFileChanged)
+	}
+
+	// inode is unique only on one device
+	device, inode := getFileID(info)
+	if info.Size() != f.Size || !info.ModTime().Equal(f.ModTime) || device != f.Device || inode != f.Inode {
+		return fmt.Errorf("%w: size, modification time, device or inode of file differ", errFileChanged)
+	}
+
+	fe := f.fileEntity()
+	if err = fe.getHashOfFile(cfg, h, ctx); err != nil {
+		return err
+	}
+	if fe.Hash != f.Hash {
+		return fmt.Errorf("%w: hash of file differ", errFileChanged)
+	}
+
+	return nil
+}
+
+// newPlan function create plan of action with duplicate files, files which action can't resolve save as skipped, return *plan
+func newPlan(cfg *config.Config, fInfo *filesInfo, action resolveAction) *plan {
+	p := &plan{
+		Version:        planVersion,
+		Created:        time.Now(),
+		Action:         action.name,
+		SourcePath:     cfg.App.SourcePath,
+		SourcePaths:    cfg.App.SourcePaths,
+		ReferencePaths: cfg.App.ReferencePaths,
+		HashAlgorithm:  cfg.App.HashAlgorithmName,
+		PreserveAttrs:  cfg.App.FlagPreserveAttrs,
+		Verify:         cfg.App.FlagVerify,
+	}
+	switch action.name {
+	case actionQuarantine:
+		p.QuarantinePath = cfg.App.QuarantinePath
+	case actionSymLink:
+		p.LinkTarget = cfg.App.LinkTarget
+	}
+
+	groups := append(append([]duplicateGroup(nil), fInfo.directoryGroups...), fInfo.duplicateGroups...)
+	for _, group := range groups {
+		pg := planGroup{Original: newPlanFile(group.Original), Directory: group.Directory}
+		for _, file := range group.Duplicates {
+			// hard link to original don't reclaim space, it's skipped by resolve
+			if action.name == actionHardLink && file.Inode != 0 && file.Inode == group.Original.Inode {
+				fInfo.skippedFilesList = append(fInfo.skippedFilesList, file)
+				continue
+			}
+			pg.Duplicates = append(pg.Duplicates, newPlanFile(file))
+			if action.reclaim {
+				p.ReclaimBytes += getReclaimSize(file)
+			}
+		}
+		if len(pg.Duplicates) > 0 {
+			p.Groups = append(p.Groups, pg)
+		}
+	}
+
+	return p
+}
+
+// configure method return copy of configuration with resolve mode of plan, return *config.Config and error
+func (p *plan) configure(cfg *config.Config) (*config.Config, error) {
+	algorithm, err := hasher.Get(p.HashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgPlan := *cfg
+	cfgPlan.App.NewHash = algorithm.New
+	cfgPlan.App.HashAlgorithmName = p.HashAlgorithm
+	cfgPlan.App.SourcePath = p.SourcePath
+	cfgPlan.App.SourcePaths = p.SourcePaths
+	cfgPlan.App.ReferencePaths = p.ReferencePaths
+	cfgPlan.App.FlagDryRun = false
+	cfgPlan.App.FlagDelete = false
+	cfgPlan.App.LinkMode = ""
+	cfgPlan.App.QuarantinePath = ""
+	cfgPlan.App.FlagTrash = false
+	cfgPlan.App.FlagPreserveAttrs = p.PreserveAttrs
+	cfgPlan.App.FlagVerify = cfg.App.FlagVerify || p.Verify
+	if p.LinkTarget != "" {
+		cfgPlan.App.LinkTarget = p.LinkTarget
+	}
+
+	switch p.Action {
+	case actionDelete:
+		cfgPlan.App.FlagDelete = true
+	case actionHardLink:
+		cfgPlan.App.LinkMode = config.LinkHard
+	case actionSymLink:
+		cfgPlan.App.LinkMode = config.LinkSym
+	case actionReflink:
+		cfgPlan.App.LinkMode = config.LinkReflink
+	case actionQuarantine:
+		cfgPlan.App.QuarantinePath = p.QuarantinePath
+	case actionTrash:
+		cfgPlan.App.FlagTrash = true
+	default:
+		return nil, fmt.Errorf("unknown action %q", p.Action)
+	}
+
+	return &cfgPlan, nil
+}
+
+// save method write plan to file, create parent directories, return error
+func (p *plan) save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// readPlan function read plan from file, return *plan and error
+func readPlan(path string) (*plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plan{}
+	if err = json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	if p.Version != planVersion {
+		return nil, fmt.Errorf("unsupported version of plan %d", p.Version)
+	}
+
+	return p, nil
+}
+
+// savePlanOfRun function print and save plan of action with duplicate files instead of resolve, files don't touch, return error
+func savePlanOfRun(cfg *config.Config, fInfo *filesInfo, action resolveAction, ctx context.Context) error {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "savePlanOfRun")
+	defer span.Finish()
+
+	p := newPlan(cfg, fInfo, action)
+	countFiles := 0
+	for _, group := range p.Groups {
+		for _, file := range group.Duplicates {
+			fmt.Printf("Would %s: %s	Original file: %s\n", action.name, file.Path, group.Original.Path)
+		}
+		countFiles += len(group.Duplicates)
+	}
+
+	path, err := cfg.GetPlanPath()
+	if err != nil {
+		cfg.App.Logger.Error("Error on get path of plan.",
+			zap.Error(err),
+		)
+		return err
+	}
+	if err = p.save(path); err != nil {
+		cfg.App.Logger.Error("Error on save plan.",
+			zap.String("plan", path),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	printSkipped(fInfo)
+	fmt.Printf("Files would be %s: %d\n", action.done, countFiles)
+	fmt.Printf("Bytes would be reclaimed: %d\n", p.ReclaimBytes)
+	fmt.Printf("Plan for apply: %s\n", path)
+
+	return nil
+}
+
+// ApplyPlan function resolve duplicate files by plan of dry run, files which changed since plan was made are skipped, return error
+func ApplyPlan(cfg *config.Config, path string, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "ApplyPlan")
+	defer span.Finish()
+
+	p, err := readPlan(path)
+	if err != nil {
+		cfg.App.Logger.Error("Error on read plan.",
+			zap.String("plan", path),
+			zap.Error(err),
+		)
+		return err
+	}
+	cfgPlan, err := p.configure(cfg)
+	if err != nil {
+		cfg.App.Logger.Error("Error on configure resolve mode of plan.",
+			zap.String("plan", path),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	// don't trust plan, files can be changed after dry run
+	fInfo := filesInfo{}
+	h := cfgPlan.App.NewHash()
+	countFiles := 0
+	for _, group := range p.Groups {
+		if err := group.Original.check(cfgPlan, h, ctx); err != nil {
+			cfg.App.Logger.Warn("Original file changed since plan, group skipped.",
+				zap.String("original", group.Original.Path),
+				zap.Error(err),
+			)
+			for _, file := range group.Duplicates {
+				fInfo.skippedFilesList = append(fInfo.skippedFilesList, file.fileEntity())
+			}
+			continue
+		}
+
+		dg := duplicateGroup{Original: group.Original.fileEntity(), Directory: group.Directory}
+		for _, file := range group.Duplicates {
+			if err := file.check(cfgPlan, h, ctx); err != nil {
+				cfg.App.Logger.Warn("File changed since plan, file skipped.",
+					zap.String("file", file.Path),
+					zap.Error(err),
+				)
+				fInfo.skippedFilesList = append(fInfo.skippedFilesList, file.fileEntity())
+				continue
+			}
+			dg.Duplicates = append(dg.Duplicates, file.fileEntity())
+		}
+		if len(dg.Duplicates) == 0 {
+			continue
+		}
+		if dg.Directory {
+			fInfo.directoryGroups = append(fInfo.directoryGroups, dg)
+		} else {
+			fInfo.duplicateGroups = append(fInfo.duplicateGroups, dg)
+		}
+		countFiles += len(dg.Duplicates)
+	}
+
+	action := getResolveAction(cfgPlan)
+	fmt.Printf("Files for %s by plan: %d\n", action.name, countFiles)
+	if countFiles == 0 {
+		printSkipped(&fInfo)
+		fmt.Printf("No files for %s!\n", action.name)
+		return nil
+	}
+
+	approved, err := getApproval(cfgPlan, action.question)
+	if err != nil {
+		return err
+	}
+	if !approved {
+		return nil
+	}
+
+	return resolveWithJournal(cfgPlan, &fInfo, action, ctx)
+}
@@ -0,0 +1,46 @@
+package filework
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// isProtected function check path or one of its parent directories match glob of protected paths,
+// glob without separator match name of file or directory, return bool
+func isProtected(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		matchName := !strings.ContainsRune(pattern, filepath.Separator)
+		for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+			name := p
+			if matchName {
+				name = filepath.Base(p)
+			}
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+			if filepath.Dir(p) == p {
+				break
+			}
+		}
+	}
+
+	return false
+}
+
+// splitProtected function split copies of original to copies which can be resolved and protected copies, return []FileEntity, []FileEntity
+func splitProtected(patterns []string, files []FileEntity) ([]FileEntity, []FileEntity) {
+	if len(patterns) == 0 {
+		return files, nil
+	}
+
+	var duplicates, protected []FileEntity
+	for _, file := range files {
+		if isProtected(patterns, file.Path) {
+			protected = append(protected, file)
+		} else {
+			duplicates = append(duplicates, file)
+		}
+	}
+
+	return duplicates, protected
+}
@@ -0,0 +1,267 @@
+package filework
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// quarantineManifest name of file with list of files in quarantine directory
+const quarantineManifest = "manifest.jsonl"
+
+// quarantine struct for move duplicate files to quarantine directory, tree of directory mirror source directory
+type quarantine struct {
+	mu  sync.Mutex
+	dir string // quarantine directory
+}
+
+// quarantineEntry struct for save information about one moved file in manifest
+type quarantineEntry struct {
+	Path       string    `json:"path"`       // original path of duplicate file
+	Quarantine string    `json:"quarantine"` // path of file in quarantine directory
+	Original   string    `json:"original"`   // path of original file for duplicate
+	Hash       string    `json:"hash"`       // hash of file
+	Size       int64     `json:"size"`       // size of file
+	Time       time.Time `json:"time"`       // time of move file
+}
+
+// newQuarantine function initialize new quarantine, return *quarantine
+func newQuarantine(dir string) *quarantine {
+	return &quarantine{dir: dir}
+}
+
+// moveFile method move duplicate file to quarantine directory by relative path from source directory, with several source directories
+// path in quarantine begin with number and name of source directory, before move write entry to manifest,
+// space in file system don't reclaim while file in quarantine, return count of reclaimed bytes and error
+func (q *quarantine) moveFile(cfg *config.Config, file FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "quarantine.moveFile")
+	defer span.Finish()
+
+	sourcePaths := cfg.GetSourcePaths()
+	root := getPreferredIndex(sourcePaths, file.Path)
+	if root == len(sourcePaths) {
+		return 0, fmt.Errorf("%w: file outside of source directory", errSkipFile)
+	}
+	rel, err := filepath.Rel(sourcePaths[root], file.Path)
+	if err != nil {
+		return 0, err
+	}
+	// source directories can have same names, number of directory make path unique
+	if len(sourcePaths) > 1 {
+		rel = filepath.Join(fmt.Sprintf("%d-%s", root+1, filepath.Base(sourcePaths[root])), rel)
+	}
+
+	entry := quarantineEntry{
+		Path:       file.Path,
+		Quarantine: filepath.Join(q.dir, rel),
+		Original:   original.Path,
+		Hash:       file.Hash,
+		Size:       file.Size,
+		Time:       time.Now(),
+	}
+	record, err := newJournalEntry(actionQuarantine, file, original, entry.Quarantine)
+	if err != nil {
+		return 0, err
+	}
+	record.Quarantine = q.dir
+	if err = j.write(record); err != nil {
+		return 0, err
+	}
+
+	// moved file must be listed in manifest for restore, entry of file which isn't moved is removed
+	if err = q.writeEntry(entry); err != nil {
+		return 0, err
+	}
+
+	// file in quarantine is never overwritten, move fails if path is busy
+	if err = moveFile(cfg, entry.Path, entry.Quarantine, ctx); err != nil {
+		if errRemove := q.removeEntry(entry); errRemove != nil {
+			cfg.App.Logger.Error("Error on remove entry from manifest of quarantine.",
+				zap.String("file", entry.Path),
+				zap.String("quarantine", q.dir),
+				zap.Error(errRemove),
+			)
+		}
+		if errors.Is(err, os.ErrExist) {
+			return 0, fmt.Errorf("%w: file already exist in quarantine", errSkipFile)
+		}
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// writeEntry method append entry to manifest of quarantine directory, return error
+func (q *quarantine) writeEntry(entry quarantineEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err = os.MkdirAll(q.dir, 0755); err != nil {
+		return err
+	}
+	manifest, err := os.OpenFile(filepath.Join(q.dir, quarantineManifest), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = manifest.Write(append(data, '\n')); err != nil {
+		_ = manifest.Close()
+		return err
+	}
+
+	return manifest.Close()
+}
+
+// removeEntry method delete entry of file which isn't moved from manifest of quarantine directory, entry of file moved before
+// by same path stay in manifest, return error
+func (q *quarantine) removeEntry(entry quarantineEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entries, err := readQuarantineManifest(q.dir)
+	if err != nil {
+		return err
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Path == entry.Path && entries[i].Quarantine == entry.Quarantine && entries[i].Time.Equal(entry.Time) {
+			return writeQuarantineManifest(q.dir, append(entries[:i], entries[i+1:]...))
+		}
+	}
+
+	return nil
+}
+
+// readQuarantineManifest function read all entries from manifest of quarantine directory, return []quarantineEntry and error
+func readQuarantineManifest(dir string) ([]quarantineEntry, error) {
+	manifest, err := os.Open(filepath.Join(dir, quarantineManifest))
+	if err != nil {
+		return nil, err
+	}
+	defer manifest.Close()
+
+	var entries []quarantineEntry
+	scanner := bufio.NewScanner(manifest)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry quarantineEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// RestoreQuarantine function put files from quarantine directory back to original paths by manifest,
+// entries of not restored files stay in manifest, return error
+func RestoreQuarantine(cfg *config.Config, dir string, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "RestoreQuarantine")
+	defer span.Finish()
+
+	if dir == "" {
+		return fmt.Errorf("quarantine directory isn't set")
+	}
+
+	entries, err := readQuarantineManifest(dir)
+	if err != nil {
+		cfg.App.Logger.Error("Error on read manifest of quarantine.",
+			zap.String("quarantine", dir),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	var rest []quarantineEntry
+	countRestored := 0
+	for _, entry := range entries {
+		if _, err := os.Lstat(entry.Quarantine); err != nil {
+			cfg.App.Logger.Warn("File don't exist in quarantine, entry skipped.",
+				zap.String("file", entry.Quarantine),
+				zap.Error(err),
+			)
+			continue
+		}
+		if _, err := os.Lstat(entry.Path); err == nil {
+			cfg.App.Logger.Warn("File already exist on original path, file stay in quarantine.",
+				zap.String("file", entry.Path),
+			)
+			rest = append(rest, entry)
+			continue
+		}
+
+		if err := moveFile(cfg, entry.Quarantine, entry.Path, ctx); err != nil {
+			cfg.App.Logger.Error("Error on restore file from quarantine.",
+				zap.String("file", entry.Path),
+				zap.Error(err),
+			)
+			rest = append(rest, entry)
+			continue
+		}
+		fmt.Printf("Restore file: %s\n", entry.Path)
+		countRestored++
+	}
+
+	// rewrite manifest with not restored files
+	if err = writeQuarantineManifest(dir, rest); err != nil {
+		cfg.App.Logger.Error("Error on write manifest of quarantine.",
+			zap.String("quarantine", dir),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	fmt.Printf("Restored files: %d\n", countRestored)
+	fmt.Printf("Files stay in quarantine: %d\n", len(rest))
+
+	return nil
+}
+
+// writeQuarantineManifest function rewrite manifest of quarantine directory by entries, return error
+func writeQuarantineManifest(dir string, entries []quarantineEntry) error {
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, quarantineManifest), data, 0644)
+}
+
+// removeQuarantineEntry function delete entry of file from manifest of quarantine directory after file moved back, return error
+func removeQuarantineEntry(dir string, path string) error {
+	entries, err := readQuarantineManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	var rest []quarantineEntry
+	for _, entry := range entries {
+		if entry.Quarantine != path {
+			rest = append(rest, entry)
+		}
+	}
+
+	return writeQuarantineManifest(dir, rest)
+}
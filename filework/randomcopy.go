@@ -17,13 +17,18 @@ func DoRandomCopyFiles(cfg *config.Config, ctx context.Context) error {
 	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "deleteFiles")
 	defer span.Finish()
 
+	// reference directories are read-only, copies create only in source directories
+	cfgCopy := *cfg
+	cfgCopy.App.ReferencePaths = nil
+	cfg = &cfgCopy
+
 	fInfo := filesInfo{}
-	fInfo.directoryList = append(fInfo.directoryList, cfg.App.SourcePath)
+	fInfo.directoryList = append(fInfo.directoryList, cfg.GetSourcePaths()...)
 
 	err := findAllFiles(cfg, &fInfo, ctx)
 	if err != nil {
 		cfg.App.Logger.Error("Error on find all files in source path.",
-			zap.String("path", cfg.App.SourcePath),
+			zap.Strings("path", cfg.GetSourcePaths()),
 			zap.Error(err),
 		)
 		return err
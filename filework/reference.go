@@ -0,0 +1,33 @@
+package filework
+
+import "sort"
+
+// isReference function check file placed in one of read-only reference directories, return bool
+func isReference(referencePaths []string, path string) bool {
+	return len(referencePaths) > 0 && getPreferredIndex(referencePaths, path) < len(referencePaths)
+}
+
+// selectReferenceOriginal function select original file in group of duplicate files only from files in reference directories,
+// copies are only files in source directories, other files of reference directories are ignored. Group without files of reference
+// or without files of source directories isn't group of duplicates, return duplicateGroup and bool
+func selectReferenceOriginal(referencePaths []string, group []FileEntity, compares []compareFunc) (duplicateGroup, bool) {
+	var reference, target []FileEntity
+	for _, file := range group {
+		if isReference(referencePaths, file.Path) {
+			reference = append(reference, file)
+		} else {
+			target = append(target, file)
+		}
+	}
+	if len(reference) == 0 || len(target) == 0 {
+		return duplicateGroup{}, false
+	}
+
+	sort.Slice(target, func(i, j int) bool {
+		return target[i].Path < target[j].Path
+	})
+	dg := selectOriginal(reference, compares)
+	dg.Duplicates = target
+
+	return dg, true
+}
@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package filework
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sizeDedupeChunk max size of range for one dedupe call, file systems can limit it
+const sizeDedupeChunk = 16 * 1024 * 1024
+
+// dedupeFile function share extents of source file with destination file by FIDEDUPERANGE ioctl, kernel compare content of ranges before share,
+// return count of deduplicated bytes and error
+func dedupeFile(source *os.File, destination *os.File, size int64) (int64, error) {
+	var deduped int64
+	for offset := int64(0); offset < size; {
+		length := size - offset
+		if length > sizeDedupeChunk {
+			length = sizeDedupeChunk
+		}
+
+		value := unix.FileDedupeRange{
+			Src_offset: uint64(offset),
+			Src_length: uint64(length),
+			Info: []unix.FileDedupeRangeInfo{{
+				Dest_fd:     int64(destination.Fd()),
+				Dest_offset: uint64(offset),
+			}},
+		}
+		if err := unix.IoctlFileDedupeRange(int(source.Fd()), &value); err != nil {
+			if isReflinkUnsupported(err) {
+				return deduped, fmt.Errorf("%w: %s", errReflinkUnsupported, err)
+			}
+			return deduped, err
+		}
+
+		info := value.Info[0]
+		switch {
+		case info.Status == unix.FILE_DEDUPE_RANGE_DIFFERS:
+			return deduped, errReflinkDiffers
+		case info.Status < 0:
+			err := unix.Errno(-info.Status)
+			if isReflinkUnsupported(err) {
+				return deduped, fmt.Errorf("%w: %s", errReflinkUnsupported, err)
+			}
+			return deduped, err
+		case info.Bytes_deduped == 0:
+			// kernel don't move forward, stop for avoid endless loop
+			return deduped, fmt.Errorf("%w: kernel don't deduplicate range", errReflinkUnsupported)
+		}
+
+		deduped += int64(info.Bytes_deduped)
+		offset += int64(info.Bytes_deduped)
+	}
+
+	return deduped, nil
+}
+
+// isReflinkUnsupported function check error of ioctl means file system don't support dedupe, return bool
+func isReflinkUnsupported(err error) bool {
+	return errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EINVAL) ||
+		errors.Is(err, unix.EXDEV) || errors.Is(err, unix.ENOSYS)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package filework
+
+import "os"
+
+// dedupeFile function share extents of source file with destination file, it's supported only on linux, return count of deduplicated bytes and error
+func dedupeFile(source *os.File, destination *os.File, size int64) (int64, error) {
+	return 0, errReflinkUnsupported
+}
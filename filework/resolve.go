@@ -0,0 +1,367 @@
+package filework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+var (
+	errSkipFile           = errors.New("file skipped")                          // error for files which can't resolve, but it isn't failure of resolve
+	errResolveFailed      = errors.New("can't resolve files")                   // error of run with files which can't be resolved by errors
+	errReflinkUnsupported = errors.New("file system don't support reflink")     // error for file systems without dedupe of extents
+	errReflinkDiffers     = errors.New("content of file differs from original") // error for files which kernel don't dedupe by different content
+)
+
+// resolveFunc function type for resolve one duplicate file with it's original file, action write to journal before it performed,
+// return count of reclaimed bytes and error
+type resolveFunc func(cfg *config.Config, file FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error)
+
+// resolveAction struct describe action with duplicate files
+type resolveAction struct {
+	name     string      // name of action
+	question string      // question for approval from user
+	done     string      // word for result of action
+	reclaim  bool        // flag for action which free space of duplicate file
+	resolve  resolveFunc // function for resolve one file
+}
+
+// needResolve function check any resolve action is set, return bool
+func needResolve(cfg *config.Config) bool {
+	return cfg.App.FlagDelete || cfg.App.LinkMode != "" || cfg.App.QuarantinePath != "" || cfg.App.FlagTrash || cfg.App.FlagDryRun || cfg.App.RunInTest
+}
+
+// getResolveAction function return action with duplicate files by config, by default files delete, return resolveAction
+func getResolveAction(cfg *config.Config) resolveAction {
+	if cfg.App.QuarantinePath != "" {
+		return resolveAction{
+			name:     actionQuarantine,
+			question: fmt.Sprintf("Move this duplicate files to quarantine %s", cfg.App.QuarantinePath),
+			done:     "moved to quarantine",
+			resolve:  newQuarantine(cfg.App.QuarantinePath).moveFile,
+		}
+	}
+
+	if cfg.App.FlagTrash {
+		return resolveAction{
+			name:     actionTrash,
+			question: "Move this duplicate files to trash",
+			done:     "moved to trash",
+			resolve:  trashFile,
+		}
+	}
+
+	switch cfg.App.LinkMode {
+	case config.LinkHard:
+		return resolveAction{
+			name:     actionHardLink,
+			question: "Replace this duplicate files by hard links",
+			done:     "replaced by hard links",
+			reclaim:  true,
+			resolve:  hardLinkFile,
+		}
+	case config.LinkReflink:
+		return resolveAction{
+			name:     actionReflink,
+			question: "Share content of this duplicate files with originals by reflinks",
+			done:     "deduplicated by reflinks",
+			reclaim:  true,
+			resolve:  reflinkFile,
+		}
+	case config.LinkSym:
+		return resolveAction{
+			name:     actionSymLink,
+			question: "Replace this duplicate files by symbolic links",
+			done:     "replaced by symbolic links",
+			reclaim:  true,
+			resolve:  symLinkFile,
+		}
+	default:
+		return resolveAction{
+			name:     actionDelete,
+			question: "Delete this duplicate files",
+			done:     "deleted",
+			reclaim:  true,
+			resolve:  deleteFile,
+		}
+	}
+}
+
+// resolveFiles function resolve duplicate files by action, every action write to journal and mark as done after success,
+// files which can't be resolved by errors save as failed, return error with count of failed files
+func resolveFiles(cfg *config.Config, fInfo *filesInfo, action resolveAction, j *journal, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "resolveFiles")
+	defer span.Finish()
+
+	wp := newWorkerPool(cfg.App.CountGoroutine)
+	countFailed := 0
+
+	for _, group := range fInfo.duplicateGroups {
+		for _, file := range group.Duplicates {
+			wp.wg.Add(1)
+			go func(file FileEntity, original FileEntity) {
+				defer func() {
+					// read to release a slot
+					<-wp.semaphoreChan
+					wp.wg.Done()
+				}()
+				// block while full
+				wp.semaphoreChan <- struct{}{}
+
+				// protected file and file of reference can't be touched by any action, plan of dry run can be made without protection
+				reference := isReference(cfg.App.ReferencePaths, file.Path)
+				if reference || isProtected(cfg.App.ProtectedPaths, file.Path) {
+					message := "File is protected, file skipped."
+					if reference {
+						message = "File is in reference directory, file skipped."
+					}
+					cfg.App.Logger.Warn(message,
+						zap.String("action", action.name),
+						zap.String("file", file.Path),
+					)
+					wp.mu.Lock()
+					fInfo.skippedFilesList = append(fInfo.skippedFilesList, file)
+					wp.mu.Unlock()
+					return
+				}
+
+				// don't trust only hash, compare content with original before resolve
+				if cfg.App.FlagVerify && !verifyDuplicate(cfg, wp, fInfo, file, original, ctx) {
+					return
+				}
+
+				reclaimed, err := action.resolve(cfg, file, original, j, ctx)
+				if errors.Is(err, errSkipFile) {
+					cfg.App.Logger.Warn("File skipped.",
+						zap.String("action", action.name),
+						zap.String("file", file.Path),
+						zap.String("original", original.Path),
+						zap.Error(err),
+					)
+					wp.mu.Lock()
+					fInfo.skippedFilesList = append(fInfo.skippedFilesList, file)
+					wp.mu.Unlock()
+					return
+				}
+				if err != nil {
+					cfg.App.Logger.Error("Error on resolve file.",
+						zap.String("action", action.name),
+						zap.String("file", file.Path),
+						zap.Error(err),
+					)
+					wp.mu.Lock()
+					fInfo.failedFilesList = append(fInfo.failedFilesList, file)
+					countFailed++
+					wp.mu.Unlock()
+					return
+				}
+				if err = j.done(action.name, file.Path); err != nil {
+					cfg.App.Logger.Error("Error on write journal.",
+						zap.String("action", action.name),
+						zap.String("file", file.Path),
+						zap.Error(err),
+					)
+				}
+
+				wp.mu.Lock()
+				fInfo.resolvedFilesList = append(fInfo.resolvedFilesList, file)
+				fInfo.reclaimedBytes += reclaimed
+				wp.mu.Unlock()
+			}(file, group.Original)
+		}
+	}
+	wp.wg.Wait()
+
+	if countFailed > 0 {
+		return fmt.Errorf("%w: %d files aren't %s", errResolveFailed, countFailed, action.done)
+	}
+
+	return nil
+}
+
+// verifyDuplicate function compare duplicate with original byte by byte, files with different content save as hash collision, return bool
+func verifyDuplicate(cfg *config.Config, wp *workerPool, fInfo *filesInfo, file FileEntity, original FileEntity, ctx context.Context) bool {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "verifyDuplicate")
+	defer span.Finish()
+
+	equal, err := compareFiles(cfg, original.Path, file.Path, ctx)
+	if err != nil {
+		cfg.App.Logger.Error("Can't verify file, file skipped.",
+			zap.String("file", file.Path),
+			zap.String("original", original.Path),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	if !equal {
+		cfg.App.Logger.Warn("Hash collision, file has different content with original, file skipped.",
+			zap.String("file", file.Path),
+			zap.String("original", original.Path),
+			zap.String("hash", file.Hash),
+		)
+		wp.mu.Lock()
+		fInfo.collisionFilesList = append(fInfo.collisionFilesList, file)
+		wp.mu.Unlock()
+	}
+
+	return equal
+}
+
+// deleteFile function delete duplicate file, return count of reclaimed bytes and error
+func deleteFile(cfg *config.Config, file FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error) {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "deleteFile")
+	defer span.Finish()
+
+	if err := writeJournal(j, actionDelete, file, original, ""); err != nil {
+		return 0, err
+	}
+
+	cfg.App.Logger.With(zap.String("file", file.Path), zap.String("original", original.Path)).Debug("Delete file.")
+	if err := os.Remove(file.Path); err != nil {
+		return 0, err
+	}
+
+	return getReclaimSize(file), nil
+}
+
+// hardLinkFile function atomically replace duplicate file by hard link to original: create link with temporary name and rename it,
+// files on different devices and files with different attributes (if user want preserve it) are skipped, return count of reclaimed bytes and error
+func hardLinkFile(cfg *config.Config, file FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error) {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "hardLinkFile")
+	defer span.Finish()
+
+	fileInfo, err := os.Lstat(file.Path)
+	if err != nil {
+		return 0, err
+	}
+	originalInfo, err := os.Lstat(original.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	fileDev, fileIno := getFileID(fileInfo)
+	originalDev, originalIno := getFileID(originalInfo)
+	if fileDev != originalDev {
+		return 0, fmt.Errorf("%w: hard link can't cross devices", errSkipFile)
+	}
+	if fileIno == originalIno {
+		return 0, fmt.Errorf("%w: file already linked with original", errSkipFile)
+	}
+
+	// hard link share attributes with original, it can't keep own attributes of duplicate
+	if cfg.App.FlagPreserveAttrs {
+		fileUID, fileGID := getFileOwner(fileInfo)
+		originalUID, originalGID := getFileOwner(originalInfo)
+		if fileInfo.Mode().Perm() != originalInfo.Mode().Perm() || fileUID != originalUID || fileGID != originalGID {
+			return 0, fmt.Errorf("%w: permissions or owner of file differ from original", errSkipFile)
+		}
+	}
+
+	if err = writeJournal(j, actionHardLink, file, original, original.Path); err != nil {
+		return 0, err
+	}
+
+	cfg.App.Logger.With(zap.String("file", file.Path), zap.String("original", original.Path)).Debug("Replace file by hard link.")
+	tmpPath := getTempPath(file.Path)
+	if err = os.Link(original.Path, tmpPath); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmpPath, file.Path); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+
+	return getReclaimSize(file), nil
+}
+
+// symLinkFile function atomically replace duplicate file by symbolic link to original with relative or absolute target:
+// create link with temporary name and rename it, return count of reclaimed bytes and error
+func symLinkFile(cfg *config.Config, file FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error) {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "symLinkFile")
+	defer span.Finish()
+
+	target, err := filepath.Abs(original.Path)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.App.LinkTarget == config.LinkTargetRelative {
+		dir, err := filepath.Abs(filepath.Dir(file.Path))
+		if err != nil {
+			return 0, err
+		}
+		if target, err = filepath.Rel(dir, target); err != nil {
+			return 0, err
+		}
+	}
+
+	if err = writeJournal(j, actionSymLink, file, original, target); err != nil {
+		return 0, err
+	}
+
+	cfg.App.Logger.With(zap.String("file", file.Path), zap.String("target", target)).Debug("Replace file by symbolic link.")
+	tmpPath := getTempPath(file.Path)
+	if err = os.Symlink(target, tmpPath); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmpPath, file.Path); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+
+	return getReclaimSize(file), nil
+}
+
+// reflinkFile function share content of duplicate file with original by dedupe of extents, files stay independent and writable,
+// on file systems without reflink files are skipped, return count of deduplicated bytes and error
+func reflinkFile(cfg *config.Config, file FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "reflinkFile")
+	defer span.Finish()
+
+	source, err := os.Open(original.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer fileClose(cfg, source, ctx)
+
+	// destination of dedupe must be open for write
+	destination, err := os.OpenFile(file.Path, os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer fileClose(cfg, destination, ctx)
+
+	if err = writeJournal(j, actionReflink, file, original, original.Path); err != nil {
+		return 0, err
+	}
+
+	cfg.App.Logger.With(zap.String("file", file.Path), zap.String("original", original.Path)).Debug("Dedupe file by reflink.")
+	deduped, err := dedupeFile(source, destination, file.Size)
+	if errors.Is(err, errReflinkUnsupported) || errors.Is(err, errReflinkDiffers) {
+		return deduped, fmt.Errorf("%w: %s", errSkipFile, err)
+	}
+
+	return deduped, err
+}
+
+// writeJournal function create entry of action with duplicate file and write it to journal, return error
+func writeJournal(j *journal, action string, file FileEntity, original FileEntity, target string) error {
+	entry, err := newJournalEntry(action, file, original, target)
+	if err != nil {
+		return err
+	}
+
+	return j.write(entry)
+}
+
+// getTempPath function return unique temporary path in directory of file, return string
+func getTempPath(path string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.%d.fileworker", filepath.Base(path), time.Now().UnixNano()))
+}
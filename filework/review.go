@@ -0,0 +1,116 @@
+package filework
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// reviewGroups function walk through duplicate groups with user: user choose file for keep, skip group, keep proposed originals
+// in all remaining groups or skip all remaining groups, end of input skip remaining groups, return reviewed groups and error
+func reviewGroups(cfg *config.Config, groups []duplicateGroup, in io.Reader, out io.Writer, ctx context.Context) ([]duplicateGroup, error) {
+	span, _ := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "reviewGroups")
+	defer span.Finish()
+
+	// group without copies for resolve don't need review, it doesn't count in numbers of groups
+	count := 0
+	for _, group := range groups {
+		if len(group.Duplicates) > 0 {
+			count++
+		}
+	}
+
+	scanner := bufio.NewScanner(in)
+	var reviewed []duplicateGroup
+	number := 0
+	for i, group := range groups {
+		if len(group.Duplicates) == 0 {
+			continue
+		}
+		number++
+
+		files := append([]FileEntity{group.Original}, group.Duplicates...)
+		printGroup(out, group, files, number, count)
+
+		for {
+			fmt.Fprintf(out, "Keep file [1-%d], Enter - keep original, s - skip group, a - keep originals in all remaining groups, q - skip all remaining groups: ", len(files))
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					cfg.App.Logger.Warn("Error on read choice of user.",
+						zap.Error(err),
+					)
+					return nil, err
+				}
+				fmt.Fprintln(out)
+				return reviewed, nil
+			}
+
+			choice := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			switch choice {
+			case "":
+				reviewed = append(reviewed, group)
+			case "s":
+			case "a":
+				reviewed = append(reviewed, group)
+				for _, next := range groups[i+1:] {
+					if len(next.Duplicates) > 0 {
+						reviewed = append(reviewed, next)
+					}
+				}
+				return reviewed, nil
+			case "q":
+				return reviewed, nil
+			default:
+				n, err := strconv.Atoi(choice)
+				if err != nil || n < 1 || n > len(files) {
+					fmt.Fprintf(out, "Wrong choice: %q\n", choice)
+					continue
+				}
+				reviewed = append(reviewed, keepFile(group, files, n-1))
+			}
+			break
+		}
+	}
+
+	return reviewed, nil
+}
+
+// printGroup function print files of duplicate group with size and modification time, protected files print without number
+func printGroup(out io.Writer, group duplicateGroup, files []FileEntity, number int, count int) {
+	kind := "Group"
+	if group.Directory {
+		kind = "Group of directories"
+	}
+	fmt.Fprintf(out, "%s %d/%d, size: %d, hash: %s\n", kind, number, count, group.Original.Size, group.Original.Hash)
+	for i, file := range files {
+		mark := ""
+		if i == 0 {
+			mark = " (original)"
+		}
+		fmt.Fprintf(out, "  [%d] %s	%d bytes	%s%s\n", i+1, file.Path, file.Size, file.Create.Format("2006-01-02 15:04:05"), mark)
+	}
+	for _, file := range group.Protected {
+		fmt.Fprintf(out, "  [-] %s	%d bytes	%s (protected)\n", file.Path, file.Size, file.Create.Format("2006-01-02 15:04:05"))
+	}
+}
+
+// keepFile function make file with index original of group, other files become copies, return duplicateGroup
+func keepFile(group duplicateGroup, files []FileEntity, index int) duplicateGroup {
+	duplicates := make([]FileEntity, 0, len(files)-1)
+	duplicates = append(duplicates, files[:index]...)
+	duplicates = append(duplicates, files[index+1:]...)
+
+	return duplicateGroup{
+		Original:   files[index],
+		Duplicates: duplicates,
+		Protected:  group.Protected,
+		Directory:  group.Directory,
+	}
+}
@@ -0,0 +1,199 @@
+package filework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// trashInfoFormat format of .trashinfo file by freedesktop.org Trash specification
+const trashInfoFormat = "[Trash Info]\nPath=%s\nDeletionDate=%s\n"
+
+// trashFile function move duplicate file to freedesktop.org trash: home trash for files on same device with home,
+// trash in top directory of device for other files, user can restore files from file manager, return count of reclaimed bytes and error
+func trashFile(cfg *config.Config, file FileEntity, original FileEntity, j *journal, ctx context.Context) (int64, error) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "trashFile")
+	defer span.Finish()
+
+	path, err := filepath.Abs(file.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	trashDir, infoPath, err := getTrashDir(path)
+	if err != nil {
+		return 0, err
+	}
+
+	name, err := reserveTrashName(trashDir, filepath.Base(path), infoPath)
+	if err != nil {
+		return 0, err
+	}
+
+	target := filepath.Join(trashDir, "files", name)
+	if err = writeJournal(j, actionTrash, file, original, target); err != nil {
+		_ = os.Remove(filepath.Join(trashDir, "info", name+".trashinfo"))
+		return 0, err
+	}
+
+	// other process can create file with reserved name, move never overwrite it
+	cfg.App.Logger.With(zap.String("file", path), zap.String("trash", trashDir)).Debug("Move file to trash.")
+	if err = moveFile(cfg, path, target, ctx); err != nil {
+		_ = os.Remove(filepath.Join(trashDir, "info", name+".trashinfo"))
+		if errors.Is(err, os.ErrExist) {
+			return 0, fmt.Errorf("%w: file with same name created in trash", errSkipFile)
+		}
+		return 0, err
+	}
+
+	return 0, nil
+}
+
+// getTrashDir function find trash directory for file and path of file for write to .trashinfo, return string, string and error
+func getTrashDir(path string) (string, string, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", "", err
+	}
+	device, _ := getFileID(info)
+
+	// home trash
+	homeTrash, err := getHomeTrashDir()
+	if err != nil {
+		return "", "", err
+	}
+	if err = os.MkdirAll(filepath.Join(homeTrash, "files"), 0700); err != nil {
+		return "", "", err
+	}
+	if err = os.MkdirAll(filepath.Join(homeTrash, "info"), 0700); err != nil {
+		return "", "", err
+	}
+	homeInfo, err := os.Stat(homeTrash)
+	if err != nil {
+		return "", "", err
+	}
+	if homeDevice, _ := getFileID(homeInfo); homeDevice == device {
+		return homeTrash, path, nil
+	}
+
+	// trash in top directory of device, path in .trashinfo is relative to top directory
+	topDir, err := getTopDir(path, device)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err := filepath.Rel(topDir, path)
+	if err != nil {
+		return "", "", err
+	}
+	uid := strconv.Itoa(os.Getuid())
+
+	// shared trash must be directory with sticky bit, not symbolic link
+	sharedTrash := filepath.Join(topDir, ".Trash")
+	if sharedInfo, err := os.Lstat(sharedTrash); err == nil && sharedInfo.IsDir() && sharedInfo.Mode()&os.ModeSticky != 0 {
+		trashDir := filepath.Join(sharedTrash, uid)
+		if err = makeTrashDir(trashDir); err == nil {
+			return trashDir, rel, nil
+		}
+	}
+
+	trashDir := filepath.Join(topDir, ".Trash-"+uid)
+	if err = makeTrashDir(trashDir); err != nil {
+		return "", "", fmt.Errorf("%w: can't create trash on device of file: %s", errSkipFile, err)
+	}
+
+	return trashDir, rel, nil
+}
+
+// getHomeTrashDir function return path to home trash, return string and error
+func getHomeTrashDir() (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// isTrashDir function check directory is home trash or trash in top directory of device (.Trash with directories of users or .Trash-UID),
+// files in trash aren't duplicates, return bool
+func isTrashDir(path string, homeTrash string) bool {
+	if path == homeTrash {
+		return true
+	}
+
+	name := filepath.Base(path)
+	return name == ".Trash" || name == ".Trash-"+strconv.Itoa(os.Getuid())
+}
+
+// getTopDir function find top directory (mount point) of device for path, return string and error
+func getTopDir(path string, device uint64) (string, error) {
+	dir := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		info, err := os.Stat(parent)
+		if err != nil {
+			return "", err
+		}
+		if parentDevice, _ := getFileID(info); parentDevice != device {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// makeTrashDir function create trash directory with files and info subdirectories, return error
+func makeTrashDir(dir string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "files"), 0700); err != nil {
+		return err
+	}
+
+	return os.MkdirAll(filepath.Join(dir, "info"), 0700)
+}
+
+// reserveTrashName function find unique name of file in trash and atomically create it's .trashinfo file, return string and error
+func reserveTrashName(trashDir string, base string, infoPath string) (string, error) {
+	content := fmt.Sprintf(trashInfoFormat, (&url.URL{Path: infoPath}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	for i := 0; ; i++ {
+		name := base
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", base, i)
+		}
+		if _, err := os.Lstat(filepath.Join(trashDir, "files", name)); err == nil {
+			continue
+		}
+
+		info, err := os.OpenFile(filepath.Join(trashDir, "info", name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err = info.WriteString(content); err != nil {
+			_ = info.Close()
+			_ = os.Remove(info.Name())
+			return "", err
+		}
+		if err = info.Close(); err != nil {
+			return "", err
+		}
+
+		return name, nil
+	}
+}
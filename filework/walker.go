@@ -0,0 +1,382 @@
+package filework
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/White-AK111/fileworker/config"
+	"github.com/opentracing/opentracing-go"
+	"go.uber.org/zap"
+)
+
+// sizeReadDirBatch count of directory entries read at once
+const sizeReadDirBatch = 1024
+
+// fileKey struct for identify file or directory by device and inode
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// walkState struct for shared state of walk: filters and devices of source directories, visited directories
+// for detect loops of symbolic links and files found by symbolic links
+type walkState struct {
+	filters  []*scanFilter    // filters of directories and files for every source directory
+	roots    []string         // real paths of source directories, targets of symbolic links must be in it
+	rootDevs []uint64         // devices of source directories
+	trash    string           // home trash directory, it can be in source directory
+	mu       sync.Mutex       // mutex for visited directories and linked files
+	visited  map[fileKey]bool // visited directories
+	linked   []FileEntity     // files found by symbolic links, it can be found by own path too
+}
+
+// visit method mark directory as visited, directory visited before (loop of symbolic links) return false, return bool
+func (w *walkState) visit(info os.FileInfo) bool {
+	dev, ino := getFileID(info)
+	// file system don't give identity of directory, loops can't be detected
+	if dev == 0 && ino == 0 {
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := fileKey{dev: dev, ino: ino}
+	if w.visited[key] {
+		return false
+	}
+	w.visited[key] = true
+
+	return true
+}
+
+// dirJob struct for directory in queue of walk
+type dirJob struct {
+	dir   string       // path to directory
+	root  int          // index of source directory
+	rules *ignoreRules // rules of ignore files of parent directories
+}
+
+// dirQueue struct for queue of directories, workers read directories from queue and add found child directories to it,
+// walk is finished when queue is empty and no worker read directory
+type dirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    []dirJob
+	pending int // count of directories in queue and in work
+}
+
+// newDirQueue function initialize new dirQueue, return *dirQueue
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+
+	return q
+}
+
+// push method add directory to queue and wake up one worker
+func (q *dirQueue) push(job dirJob) {
+	q.mu.Lock()
+	q.jobs = append(q.jobs, job)
+	q.pending++
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// pop method wait directory from queue, last directories read first so walk go deep and queue stay short,
+// finished walk return false, return dirJob and bool
+func (q *dirQueue) pop() (dirJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.jobs) == 0 && q.pending > 0 {
+		q.cond.Wait()
+	}
+	if len(q.jobs) == 0 {
+		return dirJob{}, false
+	}
+	job := q.jobs[len(q.jobs)-1]
+	q.jobs = q.jobs[:len(q.jobs)-1]
+
+	return job, true
+}
+
+// done method mark directory as read, after last directory all waiting workers wake up and finish
+func (q *dirQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// otherDevice method check file or directory is on other file system than source directory, return bool
+func (w *walkState) otherDevice(info os.FileInfo, root int) bool {
+	dev, _ := getFileID(info)
+
+	return dev != w.rootDevs[root]
+}
+
+// findAllFiles function find all files in reference and source directories without directories, save files info in filesInfo struct:
+// directories read from queue by fixed count of workers (CountGoroutine), found files stream to channel and collector save it,
+// directories and files filter by include and exclude patterns relative to own source directory, return error
+func findAllFiles(cfg *config.Config, fInfo *filesInfo, ctx context.Context) error {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "findAllFiles")
+	defer span.Finish()
+
+	sourcePaths := cfg.GetScanPaths()
+	walk := &walkState{visited: make(map[fileKey]bool)}
+	// without home directory only trash directories in top directories of devices are skipped
+	walk.trash, _ = getHomeTrashDir()
+	for _, sourcePath := range sourcePaths {
+		filter, err := newScanFilter(cfg, sourcePath)
+		if err != nil {
+			return err
+		}
+		rootInfo, err := os.Stat(sourcePath)
+		if err != nil {
+			return err
+		}
+		rootDev, _ := getFileID(rootInfo)
+		realRoot, err := filepath.EvalSymlinks(sourcePath)
+		if err != nil {
+			return err
+		}
+		walk.filters = append(walk.filters, filter)
+		walk.roots = append(walk.roots, realRoot)
+		walk.rootDevs = append(walk.rootDevs, rootDev)
+		walk.visit(rootInfo)
+	}
+
+	wp := newWorkerPool(cfg.App.CountGoroutine)
+
+	// collector, only it write to list of files
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for fe := range wp.resultChan {
+			fInfo.allFilesList = append(fInfo.allFilesList, fe)
+		}
+	}()
+
+	queue := newDirQueue()
+	for root, sourcePath := range sourcePaths {
+		queue.push(dirJob{dir: sourcePath, root: root})
+	}
+	// count of goroutines don't depend on count of directories, child directories wait in queue
+	for i := 0; i < cfg.App.CountGoroutine; i++ {
+		wp.wg.Add(1)
+		go func() {
+			defer wp.wg.Done()
+			for {
+				job, ok := queue.pop()
+				if !ok {
+					return
+				}
+				lsFiles(job, cfg, wp, fInfo, walk, queue, ctx)
+			}
+		}()
+	}
+
+	wp.wg.Wait()
+	close(wp.resultChan)
+	<-done
+
+	// file found by symbolic link and by own path is one file
+	found := make(map[fileKey]bool, len(fInfo.allFilesList))
+	for _, fe := range fInfo.allFilesList {
+		found[fileKey{dev: fe.Device, ino: fe.Inode}] = true
+	}
+	for _, fe := range walk.linked {
+		key := fileKey{dev: fe.Device, ino: fe.Inode}
+		if fe.Inode != 0 && found[key] {
+			cfg.App.Logger.With(zap.String("file", fe.Path)).Debug("Skip file found by symbolic link, it's found before.")
+			continue
+		}
+		found[key] = true
+		fInfo.allFilesList = append(fInfo.allFilesList, fe)
+	}
+
+	return nil
+}
+
+// lsFiles function for find files in directory of job, child directories add to queue of walk, files send to result channel,
+// rules of ignore files in directory add to rules of parent directories
+func lsFiles(job dirJob, cfg *config.Config, wp *workerPool, fInfo *filesInfo, walk *walkState, queue *dirQueue, ctx context.Context) {
+	span, ctx := opentracing.StartSpanFromContextWithTracer(ctx, cfg.App.Tracer, "lsFiles")
+	defer span.Finish()
+
+	defer catchRecover(cfg, ctx)
+	defer queue.done()
+
+	dir, root, rules := job.dir, job.root, job.rules
+	cfg.App.Logger.With(zap.String("directory", dir)).Debug("Open directory.")
+	file, err := os.Open(dir)
+	if err != nil {
+		cfg.App.Logger.Error("Error opening directory",
+			zap.String("directory", dir),
+			zap.Error(err),
+		)
+		return
+	}
+	defer fileClose(cfg, file, ctx)
+
+	if cfg.App.FlagIgnoreFiles {
+		rules = readIgnoreRules(cfg, dir, rules)
+	}
+
+	for {
+		// read directory by batches, don't load big directory into memory
+		files, err := file.Readdir(sizeReadDirBatch)
+		for _, f := range files {
+			path := filepath.Join(dir, f.Name())
+			isLink := f.Mode()&os.ModeSymlink != 0
+			// symbolic links can be links to originals created by previous runs or by user
+			if isLink && cfg.App.SymlinkPolicy != config.SymlinksFollow {
+				cfg.App.Logger.With(zap.String("link", path)).Debug("Skip symbolic link.")
+				continue
+			}
+			linkPath, fileRoot := path, root
+			if isLink {
+				target, err := os.Stat(path)
+				if err != nil {
+					cfg.App.Logger.Info("Skip broken symbolic link.",
+						zap.String("link", path),
+						zap.Error(err),
+					)
+					continue
+				}
+				f = target
+				// actions with duplicate must change file, not link, all checks use path of file
+				realPath, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					cfg.App.Logger.Info("Skip symbolic link, can't get path of target.",
+						zap.String("link", path),
+						zap.Error(err),
+					)
+					continue
+				}
+				// files outside of scanned directories can't be touched by any action
+				if fileRoot = getPreferredIndex(walk.roots, realPath); fileRoot == len(walk.roots) {
+					cfg.App.Logger.Info("Skip symbolic link to path outside of source directories.",
+						zap.String("link", path),
+						zap.String("target", realPath),
+					)
+					continue
+				}
+				rel, err := filepath.Rel(walk.roots[fileRoot], realPath)
+				if err != nil {
+					cfg.App.Logger.Info("Skip symbolic link, can't get path of target.",
+						zap.String("link", path),
+						zap.Error(err),
+					)
+					continue
+				}
+				path = filepath.Join(walk.filters[fileRoot].root, rel)
+			}
+
+			if cfg.App.FlagXdev && walk.otherDevice(f, fileRoot) {
+				cfg.App.Logger.Info("Skip path on other file system.", zap.String("path", path))
+				continue
+			}
+			// quarantine directory can be in source directory, files in it aren't duplicates
+			if f.IsDir() && path == cfg.App.QuarantinePath {
+				cfg.App.Logger.With(zap.String("directory", path)).Debug("Skip quarantine directory.")
+				continue
+			}
+			// trashed files can't be originals, else file in use is trashed or deleted
+			if f.IsDir() && isTrashDir(path, walk.trash) {
+				cfg.App.Logger.With(zap.String("directory", path)).Debug("Skip trash directory.")
+				continue
+			}
+			if f.IsDir() && walk.filters[fileRoot].skipDir(path) {
+				cfg.App.Logger.With(zap.String("directory", path)).Debug("Skip excluded directory.")
+				continue
+			}
+			// read of FIFO block scan, devices and sockets aren't files with content
+			if !f.IsDir() && !f.Mode().IsRegular() {
+				cfg.App.Logger.Info("Skip non-regular file.",
+					zap.String("file", path),
+					zap.String("type", getFileType(f.Mode())),
+				)
+				continue
+			}
+			if rules.ignored(path, f.IsDir()) || (isLink && rules.ignored(linkPath, f.IsDir())) {
+				cfg.App.Logger.With(zap.String("path", path)).Debug("Skip path by rules of ignore files.")
+				continue
+			}
+			// filter of MIME type read file, it's last check of file
+			if !f.IsDir() {
+				if skip, reason := walk.filters[fileRoot].skipFile(path, f); skip {
+					cfg.App.Logger.With(zap.String("file", path), zap.String("reason", reason)).Debug("Skip file by filters.")
+					continue
+				}
+			}
+			if f.IsDir() {
+				if !walk.visit(f) {
+					cfg.App.Logger.Info("Skip directory visited before, loop of symbolic links.", zap.String("directory", path))
+					continue
+				}
+				cfg.App.Logger.With(zap.String("directory", path)).Debug("Go to child directory.")
+				wp.mu.Lock()
+				fInfo.directoryList = append(fInfo.directoryList, path)
+				wp.mu.Unlock()
+				if cfg.App.DoPanic {
+					panic("Panic!")
+				}
+				queue.push(dirJob{dir: path, root: fileRoot, rules: rules})
+				continue
+			}
+
+			cfg.App.Logger.With(zap.String("file", path)).Debug("Find file in directory.")
+			fe := newFileEntity()
+			fe.Name = filepath.Base(path)
+			fe.Path = path
+			fe.Create = f.ModTime()
+			fe.Size = f.Size()
+			fe.Device, fe.Inode = getFileID(f)
+			fe.Links = getFileLinks(f)
+			if !isLink {
+				wp.resultChan <- *fe
+				continue
+			}
+			walk.mu.Lock()
+			walk.linked = append(walk.linked, *fe)
+			walk.mu.Unlock()
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cfg.App.Logger.Error("Error reading directory",
+				zap.String("directory", dir),
+				zap.Error(err),
+			)
+			break
+		}
+	}
+}
+
+// getFileType function return name of type of non-regular file, return string
+func getFileType(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character device"
+	case mode&os.ModeDevice != 0:
+		return "device"
+	case mode&os.ModeSymlink != 0:
+		return "symbolic link"
+	default:
+		return "irregular file"
+	}
+}
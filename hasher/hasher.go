@@ -0,0 +1,104 @@
+// Package hasher contains registry of hash algorithms for compare files
+// application and library users can register own algorithms
+package hasher
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sort"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Algorithm struct describe registered hash algorithm
+type Algorithm struct {
+	Name          string           // name of algorithm
+	New           func() hash.Hash // factory of new hash
+	Cryptographic bool             // false for fast non-cryptographic hashes, it's files need verify byte by byte
+}
+
+var (
+	mu         sync.RWMutex
+	algorithms = make(map[string]Algorithm)
+)
+
+func init() {
+	register("sha256", sha256.New, true)
+	register("sha1", sha1.New, true)
+	register("md5", md5.New, true)
+	register("blake2b", newBlake2b, true)
+	register("xxhash64", func() hash.Hash { return xxhash.New() }, false)
+	register("crc32", func() hash.Hash { return crc32.NewIEEE() }, false)
+}
+
+// Register function add hash algorithm to registry, algorithm with same name is replaced, return error
+func Register(name string, factory func() hash.Hash, cryptographic bool) error {
+	if name == "" {
+		return errors.New("empty name of hash algorithm")
+	}
+	if factory == nil {
+		return fmt.Errorf("nil factory of hash algorithm %q", name)
+	}
+
+	register(name, factory, cryptographic)
+
+	return nil
+}
+
+// Get function find hash algorithm in registry by name, return Algorithm and error
+func Get(name string) (Algorithm, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	algorithm, ok := algorithms[name]
+	if !ok {
+		return Algorithm{}, fmt.Errorf("unknown hash algorithm %q, available: %v", name, names())
+	}
+
+	return algorithm, nil
+}
+
+// Names function return sorted names of all registered hash algorithms, return []string
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return names()
+}
+
+// register function add hash algorithm to registry without checks
+func register(name string, factory func() hash.Hash, cryptographic bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	algorithms[name] = Algorithm{
+		Name:          name,
+		New:           factory,
+		Cryptographic: cryptographic,
+	}
+}
+
+// names function return sorted names of algorithms, caller must hold lock, return []string
+func names() []string {
+	list := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// newBlake2b function create BLAKE2b-256 hash, return hash.Hash
+func newBlake2b() hash.Hash {
+	// error return only for wrong key, hash without key can't fail
+	h, _ := blake2b.New256(nil)
+	return h
+}
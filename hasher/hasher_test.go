@@ -0,0 +1,44 @@
+package hasher
+
+import (
+	"encoding/hex"
+	"hash"
+	"hash/adler32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGet test for Get function, all built-in algorithms are registered
+func TestGet(t *testing.T) {
+	for _, name := range []string{"sha256", "sha1", "md5", "blake2b", "xxhash64", "crc32"} {
+		algorithm, err := Get(name)
+		if err != nil {
+			t.Fatalf("error on get hash algorithm: %s", err)
+		}
+		h := algorithm.New()
+		_, _ = h.Write([]byte("fileworker"))
+		assert.NotEmpty(t, hex.EncodeToString(h.Sum(nil)), "empty hash of algorithm %s", name)
+	}
+
+	_, err := Get("unknown")
+	assert.Error(t, err)
+}
+
+// TestRegister test for Register function, user can register own algorithm
+func TestRegister(t *testing.T) {
+	err := Register("adler32", func() hash.Hash { return adler32.New() }, false)
+	if err != nil {
+		t.Fatalf("error on register hash algorithm: %s", err)
+	}
+
+	algorithm, err := Get("adler32")
+	if err != nil {
+		t.Fatalf("error on get hash algorithm: %s", err)
+	}
+	assert.False(t, algorithm.Cryptographic)
+	assert.Contains(t, Names(), "adler32")
+
+	assert.Error(t, Register("", func() hash.Hash { return adler32.New() }, false))
+	assert.Error(t, Register("nil", nil, false))
+}
@@ -0,0 +1,225 @@
+// Package ignore contains matcher of gitignore-style patterns for filter paths on walk of directories
+// patterns with prefix "re:" are regular expressions
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// prefixRegexp prefix of pattern with regular expression
+const prefixRegexp = "re:"
+
+// Pattern struct describe one compiled pattern
+type Pattern struct {
+	re      *regexp.Regexp // compiled glob or regular expression
+	negate  bool           // pattern with "!" re-include paths excluded by previous patterns
+	dirOnly bool           // pattern with trailing "/" match only directories
+	isGlob  bool           // glob also match files in matched directories
+}
+
+// ParsePattern function compile gitignore-style glob or regular expression with prefix "re:", return *Pattern and error
+//
+// Glob with separator in begin or middle match path relative to base directory, glob without separator match name in any directory,
+// "**" match any count of directories, trailing "/" match only directories, leading "!" negate pattern.
+// Regular expression match path relative to base directory with "/" as separator.
+func ParsePattern(pattern string) (*Pattern, error) {
+	p := &Pattern{}
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	}
+
+	if strings.HasPrefix(pattern, prefixRegexp) {
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, prefixRegexp))
+		if err != nil {
+			return nil, err
+		}
+		p.re = re
+		return p, nil
+	}
+
+	// escaped "!" and "#" in begin of glob are literals
+	if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	prefix := "^(?:.*/)?"
+	if strings.Contains(pattern, "/") {
+		prefix = "^"
+		pattern = strings.TrimPrefix(pattern, "/")
+	}
+
+	expr, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(prefix + expr + "$")
+	if err != nil {
+		return nil, err
+	}
+	p.re = re
+	p.isGlob = true
+
+	return p, nil
+}
+
+// globToRegexp function convert glob to regular expression, "*" and "?" don't match separator, return string and error
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			switch {
+			case strings.HasPrefix(glob[i:], "**/"):
+				// zero or more directories
+				b.WriteString("(?:.*/)?")
+				i += 2
+			case strings.HasPrefix(glob[i:], "**"):
+				b.WriteString(".*")
+				i++
+			default:
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed character class in pattern %q", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String(), nil
+}
+
+// match method check relative path match pattern, glob also match paths in matched directories, return bool
+func (p *Pattern) match(rel string, isDir bool) bool {
+	if !p.isGlob {
+		return p.re.MatchString(rel)
+	}
+
+	if (isDir || !p.dirOnly) && p.re.MatchString(rel) {
+		return true
+	}
+	// parent directories of path
+	for i := len(rel) - 1; i > 0; i-- {
+		if rel[i] == '/' && p.re.MatchString(rel[:i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Matcher struct contains list of patterns, last matched pattern decide result like in .gitignore
+type Matcher struct {
+	patterns []*Pattern
+}
+
+// NewMatcher function compile list of patterns, empty lines and lines with "#" in begin are skipped, return *Matcher and error
+func NewMatcher(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, pattern := range patterns {
+		if err := m.add(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ReadMatcher function read patterns from file in .gitignore format, return *Matcher and error
+func ReadMatcher(r io.Reader) (*Matcher, error) {
+	m := &Matcher{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// trailing spaces ignored, except escaped
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if strings.HasSuffix(line, `\`) {
+			line += " "
+		}
+		if err := m.add(line); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, scanner.Err()
+}
+
+// Merge function join patterns of matchers, patterns of next matcher have higher priority, return *Matcher
+func Merge(matchers ...*Matcher) *Matcher {
+	m := &Matcher{}
+	for _, matcher := range matchers {
+		if matcher != nil {
+			m.patterns = append(m.patterns, matcher.patterns...)
+		}
+	}
+
+	return m
+}
+
+// add method compile pattern and add it to matcher, skip empty patterns and comments, return error
+func (m *Matcher) add(pattern string) error {
+	if strings.TrimSpace(pattern) == "" || strings.HasPrefix(pattern, "#") {
+		return nil
+	}
+
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return fmt.Errorf("wrong pattern %q: %w", pattern, err)
+	}
+	m.patterns = append(m.patterns, p)
+
+	return nil
+}
+
+// Len method return count of patterns
+func (m *Matcher) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.patterns)
+}
+
+// Match method check relative path with "/" as separator by patterns, return result of last matched pattern
+// (true for path matched by pattern without "!") and flag of any pattern matched path
+func (m *Matcher) Match(rel string, isDir bool) (bool, bool) {
+	if m == nil {
+		return false, false
+	}
+
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		if m.patterns[i].match(rel, isDir) {
+			return !m.patterns[i].negate, true
+		}
+	}
+
+	return false, false
+}
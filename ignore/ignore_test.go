@@ -0,0 +1,87 @@
+package ignore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMatcher test for Match method of Matcher with gitignore-style globs and regular expressions
+func TestMatcher(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		path     string
+		isDir    bool
+		matched  bool
+	}{
+		{patterns: []string{".git"}, path: ".git", isDir: true, matched: true},
+		{patterns: []string{"node_modules"}, path: "web/node_modules", isDir: true, matched: true},
+		{patterns: []string{"node_modules"}, path: "web/node_modules/pkg/index.js", matched: true},
+		{patterns: []string{"*.log"}, path: "logs/app.log", matched: true},
+		{patterns: []string{"*.log"}, path: "logs/app.txt", matched: false},
+		{patterns: []string{"/vendor"}, path: "vendor", isDir: true, matched: true},
+		{patterns: []string{"/vendor"}, path: "src/vendor", isDir: true, matched: false},
+		{patterns: []string{"build/"}, path: "build", isDir: false, matched: false},
+		{patterns: []string{"build/"}, path: "build", isDir: true, matched: true},
+		{patterns: []string{"build/"}, path: "build/out.o", matched: true},
+		{patterns: []string{"docs/*.md"}, path: "docs/a.md", matched: true},
+		{patterns: []string{"docs/*.md"}, path: "docs/sub/a.md", matched: false},
+		{patterns: []string{"docs/**/*.md"}, path: "docs/sub/a.md", matched: true},
+		{patterns: []string{"**/tmp"}, path: "a/b/tmp", isDir: true, matched: true},
+		{patterns: []string{"file?.txt"}, path: "file1.txt", matched: true},
+		{patterns: []string{"file[!0-4].txt"}, path: "file1.txt", matched: false},
+		{patterns: []string{"file[!0-4].txt"}, path: "file5.txt", matched: true},
+		{patterns: []string{"*.txt", "!keep.txt"}, path: "keep.txt", matched: false},
+		{patterns: []string{"!keep.txt", "*.txt"}, path: "keep.txt", matched: true},
+		{patterns: []string{`re:^src/.*_test\.go$`}, path: "src/a_test.go", matched: true},
+		{patterns: []string{`re:^src/.*_test\.go$`}, path: "src/a.go", matched: false},
+	}
+
+	for _, test := range tests {
+		m, err := NewMatcher(test.patterns)
+		if err != nil {
+			t.Fatalf("error on compile patterns %v: %s", test.patterns, err)
+		}
+		matched, _ := m.Match(test.path, test.isDir)
+		assert.Equal(t, test.matched, matched, "patterns %v, path %s", test.patterns, test.path)
+	}
+}
+
+// TestReadMatcher test for ReadMatcher function, comments and empty lines are skipped
+func TestReadMatcher(t *testing.T) {
+	m, err := ReadMatcher(strings.NewReader("# comment\n\n*.tmp\n!important.tmp\n\\#file\n"))
+	if err != nil {
+		t.Fatalf("error on read patterns: %s", err)
+	}
+	assert.Equal(t, 3, m.Len())
+
+	ignored, matched := m.Match("a.tmp", false)
+	assert.True(t, ignored)
+	assert.True(t, matched)
+	ignored, matched = m.Match("important.tmp", false)
+	assert.False(t, ignored)
+	assert.True(t, matched)
+	ignored, _ = m.Match("#file", false)
+	assert.True(t, ignored)
+	_, matched = m.Match("a.txt", false)
+	assert.False(t, matched)
+
+	_, err = NewMatcher([]string{"re:("})
+	assert.Error(t, err)
+	_, err = NewMatcher([]string{"file[0-9"})
+	assert.Error(t, err)
+}
+
+// TestMerge test for Merge function, patterns of next matcher override patterns of previous
+func TestMerge(t *testing.T) {
+	first, _ := NewMatcher([]string{"*.tmp"})
+	second, _ := NewMatcher([]string{"!keep.tmp"})
+
+	m := Merge(first, nil, second)
+	assert.Equal(t, 2, m.Len())
+	ignored, _ := m.Match("keep.tmp", false)
+	assert.False(t, ignored)
+	ignored, _ = m.Match("a.tmp", false)
+	assert.True(t, ignored)
+}
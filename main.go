@@ -16,6 +16,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"io"
 	"log"
 
@@ -66,12 +67,63 @@ func main() {
 	cfg.App.Logger.Info("Start init flags.")
 	err = cfg.InitFlags()
 	if err != nil {
-		cfg.App.Logger.Warn("Error on init flags",
+		// wrong flags can change action with files, don't continue
+		cfg.App.Logger.Fatal("Error on init flags",
 			zap.Error(err),
 		)
 	}
 	cfg.App.Logger.Info("Flags successfully init.")
 
+	// exec command if it set
+	switch flag.Arg(0) {
+	case "prune-cache":
+		cfg.App.Logger.Info("Start prune hash cache.")
+		if err = filework.PruneCache(cfg, ctx); err != nil {
+			cfg.App.Logger.Fatal("Error on prune hash cache",
+				zap.Error(err),
+			)
+		}
+		cfg.App.Logger.Info("Successfully prune hash cache.")
+		return
+	case "restore":
+		cfg.App.Logger.Info("Start restore files from quarantine.")
+		quarantinePath := cfg.App.QuarantinePath
+		if flag.NArg() > 1 {
+			quarantinePath = flag.Arg(1)
+		}
+		if err = filework.RestoreQuarantine(cfg, quarantinePath, ctx); err != nil {
+			cfg.App.Logger.Fatal("Error on restore files from quarantine",
+				zap.Error(err),
+			)
+		}
+		cfg.App.Logger.Info("Successfully restore files from quarantine.")
+		return
+	case "undo":
+		cfg.App.Logger.Info("Start undo actions from journal.")
+		if flag.NArg() < 2 {
+			cfg.App.Logger.Fatal("Journal for undo isn't set, use: fileworker undo JOURNAL")
+		}
+		if err = filework.UndoJournal(cfg, flag.Arg(1), ctx); err != nil {
+			cfg.App.Logger.Fatal("Error on undo actions from journal",
+				zap.Error(err),
+			)
+		}
+		cfg.App.Logger.Info("Successfully undo actions from journal.")
+		return
+	case "apply":
+		cfg.App.Logger.Info("Start apply plan of dry run.")
+		if flag.NArg() < 2 {
+			cfg.App.Logger.Fatal("Plan for apply isn't set, use: fileworker apply PLAN")
+		}
+		if err = filework.ApplyPlan(cfg, flag.Arg(1), ctx); err != nil {
+			cfg.App.Logger.Fatal("Error on apply plan of dry run",
+				zap.Error(err),
+			)
+		}
+		cfg.App.Logger.Info("Successfully apply plan of dry run.")
+		return
+	}
+
 	// exec function for find and delete files
 	cfg.App.Logger.Info("Start find duplicated files.")
 	err = filework.DoDuplicateFiles(cfg, ctx)